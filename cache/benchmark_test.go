@@ -342,6 +342,48 @@ func BenchmarkHighContentionScenario(b *testing.B) {
 	})
 }
 
+// same workload spread over shards
+func BenchmarkHighContentionScenarioSharded(b *testing.B) {
+	c := NewSharded(16,
+		WithCapacity[string, string](100),
+		WithThreadSafety[string, string](true),
+	)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			key := fmt.Sprintf("key_%d", rand.Intn(50))
+			if rand.Float32() < 0.7 {
+				c.Get(key)
+			} else {
+				c.Set(key, fmt.Sprintf("value_%d", rand.Intn(1000)))
+			}
+		}
+	})
+}
+
+// same workload with buffered read hits
+func BenchmarkHighContentionScenarioReadBuffer(b *testing.B) {
+	c := New(
+		WithCapacity[string, string](100),
+		WithThreadSafety[string, string](true),
+		WithReadBuffer[string, string](true),
+	)
+	defer c.Close()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			key := fmt.Sprintf("key_%d", rand.Intn(50))
+			if rand.Float32() < 0.7 {
+				c.Get(key)
+			} else {
+				c.Set(key, fmt.Sprintf("value_%d", rand.Intn(1000)))
+			}
+		}
+	})
+}
+
 // memory allocation patterns
 func BenchmarkMemoryAllocations(b *testing.B) {
 	c := New(WithCapacity[string, string](1000))
@@ -1,7 +1,9 @@
 package cache
 
 import (
+	"caching-lib/codec"
 	"caching-lib/eviction"
+	"caching-lib/hasher"
 	"caching-lib/storage"
 	"sync"
 	"sync/atomic"
@@ -10,7 +12,7 @@ import (
 
 type cache[K comparable, V any] struct {
 	storage    storage.Storage[K, V]
-	policy     eviction.Policy[K]
+	policies   [numPriorities]eviction.Policy[K]
 	capacity   int
 	defaultTTL time.Duration
 	maxTTL     time.Duration
@@ -30,39 +32,143 @@ type cache[K comparable, V any] struct {
 
 	// mem optimization
 	itemPool *storage.ItemPool[V]
+
+	// backing store
+	writer    Writer[K, V]
+	behind    *writeBehind[K, V]
+	closeOnce sync.Once
+
+	// removal listeners
+	removal *removalDispatcher[K, V]
+
+	// change events
+	events *eventHub[K, V]
+
+	// keys excluded from eviction
+	pinned map[K]struct{}
+
+	// non-normal priority classes, by key
+	priorities map[K]Priority
+
+	// tag index
+	tagKeys map[string]map[K]struct{}
+	keyTags map[K][]string
+
+	// dependency graph, key -> keys it depends on and the reverse
+	dependencies    map[K]map[K]struct{}
+	dependents      map[K]map[K]struct{}
+	maxCascadeDepth int
+	cascadeDepth    int
+
+	// orders keys for Scan cursors
+	hasher hasher.Hasher[K]
+
+	// last version handed to an item, never reused so deletes can't hide writes
+	version uint64
+
+	// buffered read hits, nil when reads update the policy directly
+	reads *readBuffer[K]
+
+	// receives evicted items instead of dropping them, set by NewTiered.
+	// True if it kept the item, which then isn't reported as evicted.
+	demote func(key K, item *storage.Item[V]) bool
+
+	// Snapshot encoding
+	keyCodec   codec.Codec[K]
+	valueCodec codec.Codec[V]
+
+	// key sets of namespace views, by prefix
+	namespaces map[string]*namespaceIndex
+
+	// hash-bucketed keys for Scan, nil until the first Scan
+	scanIdx *scanIndex[K]
+
+	// version of the last removal per key stripe, so transactions can tell
+	// an absent key was touched. Nil until the first Begin.
+	tombstones []uint64
 }
 
 func New[K comparable, V any](opts ...Option[K, V]) Cache[K, V] {
+	return newCache(newConfig(opts))
+}
+
+// defaults with opts applied
+func newConfig[K comparable, V any](opts []Option[K, V]) *Config[K, V] {
 	config := &Config[K, V]{
 		Capacity:   100,
 		ThreadSafe: true,
 		DefaultTTL: 0, // no TTL by default
 		MaxTTL:     24 * time.Hour,
+
+		MaxCascadeDepth: 16,
 	}
 
 	for _, opt := range opts {
 		opt(config)
 	}
+	return config
+}
 
+func newCache[K comparable, V any](config *Config[K, V]) *cache[K, V] {
 	if config.Storage == nil {
 		config.Storage = storage.NewMemoryStorageWithConfig[K, V](config.Capacity, config.ThreadSafe)
 	}
 
+	if config.Hasher == nil {
+		config.Hasher = hasher.Default[K]()
+	}
+
 	if config.EvictionPolicy == nil {
 		config.EvictionPolicy = eviction.NewLRUWithConfig[K](config.Capacity, config.ThreadSafe)
 	}
 
+	if config.KeyCodec == nil {
+		config.KeyCodec = codec.Gob[K]{}
+	}
+	if config.ValueCodec == nil {
+		config.ValueCodec = codec.Gob[V]{}
+	}
+
 	config.Storage.Reserve(config.Capacity)
 
 	c := &cache[K, V]{
 		storage:     config.Storage,
-		policy:      config.EvictionPolicy,
 		capacity:    config.Capacity,
 		defaultTTL:  config.DefaultTTL,
 		maxTTL:      config.MaxTTL,
 		threadSafe:  config.ThreadSafe,
 		stopCleanup: make(chan struct{}),
 		itemPool:    storage.NewItemPool[V](),
+		writer:      config.Writer,
+		removal:     newRemovalDispatcher(config.RemovalListeners, config.AsyncRemoval),
+		events:      &eventHub[K, V]{},
+		pinned:      make(map[K]struct{}),
+		priorities:  make(map[K]Priority),
+		tagKeys:     make(map[string]map[K]struct{}),
+		keyTags:     make(map[K][]string),
+
+		dependencies:    make(map[K]map[K]struct{}),
+		dependents:      make(map[K]map[K]struct{}),
+		maxCascadeDepth: config.MaxCascadeDepth,
+		hasher:          config.Hasher,
+		keyCodec:        config.KeyCodec,
+		valueCodec:      config.ValueCodec,
+	}
+	c.policies[PriorityNormal] = config.EvictionPolicy
+	for p, policy := range config.PriorityPolicies {
+		if p.valid() && p != PriorityNormal {
+			c.policies[p] = policy
+		}
+	}
+
+	if config.Writer != nil && config.WriteMode == WriteBehind {
+		c.behind = newWriteBehind(config.Writer, config.WriteBehindInterval,
+			config.WriteBehindBatchSize, config.WriteErrorHandler)
+	}
+
+	if config.ReadBuffer && config.ThreadSafe {
+		c.reads = newReadBuffer[K]()
+		go c.runMaintenance()
 	}
 
 	// start bg cleanup if TTL enabled
@@ -81,13 +187,16 @@ func (c *cache[K, V]) Get(key K) (V, bool) {
 	}
 
 	var zero V
-	item, exists := c.storage.Get(key)
+	item, exists := c.storage.Peek(key)
 	if exists && !item.IsExpired() {
-		c.policy.Access(key)
+		c.accessRead(key)
 		atomic.AddInt64(&c.hits, 1)
 		return item.Value, true
 	}
 
+	if exists {
+		c.expireFromRead(key)
+	}
 	atomic.AddInt64(&c.misses, 1)
 	return zero, false
 }
@@ -104,34 +213,7 @@ func (c *cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
 		defer c.mu.Unlock()
 	}
 
-	if ttl > c.maxTTL {
-		ttl = c.maxTTL
-	}
-
-	item := c.itemPool.Get()
-	item.Value = value
-	item.SetTTL(ttl)
-
-	// check if key exists
-	if existing, exists := c.storage.Get(key); exists && !existing.IsExpired() {
-		c.storage.Set(key, item)
-		c.policy.Access(key)
-		return true
-	}
-
-	// evict if needed
-	if c.storage.Size() >= c.capacity {
-		if evictKey, hasKey := c.policy.Evict(); hasKey {
-			c.storage.Delete(evictKey)
-			atomic.AddInt64(&c.evictions, 1)
-		}
-	}
-
-	// add new item
-	c.storage.Set(key, item)
-	c.policy.Access(key)
-
-	return true
+	return c.setLocked(key, value, ttl, PriorityNormal)
 }
 
 // Delete - removes key from cache
@@ -141,12 +223,11 @@ func (c *cache[K, V]) Delete(key K) bool {
 		defer c.mu.Unlock()
 	}
 
-	if c.storage.Delete(key) {
-		c.policy.Remove(key)
-		return true
+	if err := c.write(WriteOp[K, V]{Key: key, Delete: true}); err != nil {
+		return false
 	}
 
-	return false
+	return c.deleteLocked(key)
 }
 
 // Clear - removes all items
@@ -156,8 +237,37 @@ func (c *cache[K, V]) Clear() {
 		defer c.mu.Unlock()
 	}
 
+	// capture values before storage recycles items
+	var cleared []removalNotice[K, V]
+	if c.removal != nil || c.events.active() {
+		for key, item := range c.storage.All() {
+			cleared = append(cleared, removalNotice[K, V]{key: key, value: item.Value, reason: ReasonCleared})
+		}
+	}
+
 	c.storage.Clear()
-	c.policy.Clear()
+	for _, idx := range c.namespaces {
+		clear(idx.keys)
+	}
+	if c.scanIdx != nil {
+		c.scanIdx.reset()
+	}
+	for _, policy := range c.policies {
+		if policy != nil {
+			policy.Clear()
+		}
+	}
+	clear(c.pinned)
+	clear(c.priorities)
+	clear(c.tagKeys)
+	clear(c.keyTags)
+	clear(c.dependencies)
+	clear(c.dependents)
+	c.tombstoneAllLocked()
+	for _, n := range cleared {
+		c.notifyRemoval(n.key, n.value, n.reason)
+	}
+
 	atomic.StoreInt64(&c.hits, 0)
 	atomic.StoreInt64(&c.misses, 0)
 	atomic.StoreInt64(&c.evictions, 0)
@@ -190,8 +300,12 @@ func (c *cache[K, V]) Contains(key K) bool {
 		defer c.mu.RUnlock()
 	}
 
-	item, exists := c.storage.Get(key)
-	return exists && !item.IsExpired()
+	item, exists := c.storage.Peek(key)
+	if exists && item.IsExpired() {
+		c.expireFromRead(key)
+		return false
+	}
+	return exists
 }
 
 // stores multiple items (memory optimized)
@@ -203,6 +317,19 @@ func (c *cache[K, V]) SetBatch(items map[K]V) int {
 
 	var count int
 
+	// batch writers get the whole batch in one call
+	written := false
+	if bw, ok := c.batchWriter(); ok {
+		ops := make([]WriteOp[K, V], 0, len(items))
+		for key, value := range items {
+			ops = append(ops, WriteOp[K, V]{Key: key, Value: value})
+		}
+		if err := bw.WriteBatch(ops); err != nil {
+			return 0
+		}
+		written = true
+	}
+
 	// pre-allocate items from pool
 	poolItems := make([]*storage.Item[V], 0, len(items))
 	for range items {
@@ -211,6 +338,13 @@ func (c *cache[K, V]) SetBatch(items map[K]V) int {
 
 	i := 0
 	for key, value := range items {
+		if !written {
+			if err := c.write(WriteOp[K, V]{Key: key, Value: value}); err != nil {
+				c.itemPool.Put(poolItems[i])
+				i++
+				continue
+			}
+		}
 		if c.setBatchItem(key, value, poolItems[i]) {
 			count++
 		}
@@ -225,21 +359,8 @@ func (c *cache[K, V]) setBatchItem(key K, value V, item *storage.Item[V]) bool {
 	item.Value = value
 	item.SetTTL(c.defaultTTL)
 
-	if existing, exists := c.storage.Get(key); exists && !existing.IsExpired() {
-		c.storage.Set(key, item)
-		c.policy.Access(key)
-		return true
-	}
-
-	if c.storage.Size() >= c.capacity {
-		if evictKey, hasKey := c.policy.Evict(); hasKey {
-			c.storage.Delete(evictKey)
-			atomic.AddInt64(&c.evictions, 1)
-		}
-	}
-
-	c.storage.Set(key, item)
-	c.policy.Access(key)
+	c.setPriority(key, PriorityNormal)
+	c.storeLocked(key, item)
 	return true
 }
 
@@ -252,13 +373,17 @@ func (c *cache[K, V]) GetBatch(keys []K) map[K]V {
 
 	result := make(map[K]V, len(keys))
 	for _, key := range keys {
-		if item, exists := c.storage.Get(key); exists && !item.IsExpired() {
-			c.policy.Access(key)
+		item, exists := c.storage.Peek(key)
+		if exists && !item.IsExpired() {
+			c.accessRead(key)
 			result[key] = item.Value
 			atomic.AddInt64(&c.hits, 1)
-		} else {
-			atomic.AddInt64(&c.misses, 1)
+			continue
 		}
+		if exists {
+			c.expireFromRead(key)
+		}
+		atomic.AddInt64(&c.misses, 1)
 	}
 	return result
 }
@@ -270,10 +395,31 @@ func (c *cache[K, V]) DeleteBatch(keys []K) int {
 		defer c.mu.Unlock()
 	}
 
+	return c.deleteBatchLocked(keys)
+}
+
+// writes the deletes through, then removes keys (assumes lock held)
+func (c *cache[K, V]) deleteBatchLocked(keys []K) int {
+	written := false
+	if bw, ok := c.batchWriter(); ok {
+		ops := make([]WriteOp[K, V], 0, len(keys))
+		for _, key := range keys {
+			ops = append(ops, WriteOp[K, V]{Key: key, Delete: true})
+		}
+		if err := bw.WriteBatch(ops); err != nil {
+			return 0
+		}
+		written = true
+	}
+
 	var count int
 	for _, key := range keys {
-		if c.storage.Delete(key) {
-			c.policy.Remove(key)
+		if !written {
+			if err := c.write(WriteOp[K, V]{Key: key, Delete: true}); err != nil {
+				continue
+			}
+		}
+		if c.deleteLocked(key) {
 			count++
 		}
 	}
@@ -302,6 +448,7 @@ func (c *cache[K, V]) Stats() Stats {
 		Misses:    misses,
 		Evictions: evictions,
 		Size:      c.storage.Size(),
+		Pinned:    len(c.pinned),
 		Capacity:  c.capacity,
 		HitRatio:  hitRatio,
 	}
@@ -338,12 +485,291 @@ func (c *cache[K, V]) cleanup() {
 		defer c.mu.Unlock()
 	}
 
-	c.storage.CleanupExpired()
+	// collect first, storage is locked while iterating
+	var expired []K
+	for key, item := range c.storage.All() {
+		if item.IsExpired() {
+			expired = append(expired, key)
+		}
+	}
+	for _, key := range expired {
+		c.removeLocked(key, ReasonExpired)
+	}
+}
+
+// writes through and stores value (assumes lock held)
+func (c *cache[K, V]) setLocked(key K, value V, ttl time.Duration, priority Priority) bool {
+	return c.trySetLocked(key, value, ttl, priority) == nil
 }
 
-// stops bg cleanup
+// setLocked returning the writer's error (assumes lock held)
+func (c *cache[K, V]) trySetLocked(key K, value V, ttl time.Duration, priority Priority) error {
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+
+	if err := c.write(WriteOp[K, V]{Key: key, Value: value}); err != nil {
+		return err
+	}
+	c.setPriority(key, priority)
+
+	item := c.itemPool.Get()
+	item.Value = value
+	item.SetTTL(ttl)
+
+	c.storeLocked(key, item)
+	return nil
+}
+
+// stores value keeping the entry's current expiry (assumes lock held)
+func (c *cache[K, V]) updateLocked(key K, value V, existing *storage.Item[V]) bool {
+	if err := c.write(WriteOp[K, V]{Key: key, Value: value}); err != nil {
+		return false
+	}
+
+	item := c.itemPool.Get()
+	item.Value = value
+	item.ExpiresAt = existing.ExpiresAt
+	item.HasTTL = existing.HasTTL
+
+	c.storeLocked(key, item)
+	return true
+}
+
+// stores item under key, evicting if full (assumes lock held)
+func (c *cache[K, V]) storeLocked(key K, item *storage.Item[V]) {
+	// buffered hits first, so eviction sees current recency
+	c.drainReadsLocked()
+	item.Version = c.nextVersion()
+	if existing, exists := c.storage.Peek(key); exists {
+		old := existing.Value
+		reason := ReasonReplaced
+		if existing.IsExpired() {
+			reason = ReasonExpired
+			// tags belonged to the dead entry
+			c.untagLocked(key)
+		}
+		value := item.Value
+		c.storage.Set(key, item)
+		c.access(key)
+		c.notifyRemoval(key, old, reason)
+		if reason == ReasonReplaced {
+			c.events.publish(EventUpdate, key, value)
+		} else {
+			c.events.publish(EventSet, key, value)
+		}
+		c.cascadeLocked(key)
+		return
+	}
+
+	if c.storage.Size() >= c.capacity {
+		c.evictLocked()
+	}
+
+	c.storage.Set(key, item)
+	c.access(key)
+	c.namespaceAdd(key)
+	c.scanAdd(key)
+	c.events.publish(EventSet, key, item.Value)
+	c.cascadeLocked(key)
+}
+
+// assumes lock held
+func (c *cache[K, V]) nextVersion() uint64 {
+	c.version++
+	return c.version
+}
+
+// evicts from the lowest non-empty priority class (assumes lock held)
+func (c *cache[K, V]) evictLocked() {
+	evictKey, hasKey := c.evictCandidate(nil)
+	if !hasKey {
+		return
+	}
+
+	c.dropLocked(evictKey, ReasonEvicted)
+	atomic.AddInt64(&c.evictions, 1)
+}
+
+// next eviction candidate, lowest class first. Keys matching skip are
+// passed over and left untracked, the caller must re-track them (assumes
+// lock held)
+func (c *cache[K, V]) evictCandidate(skip func(K) bool) (K, bool) {
+	for _, policy := range c.policies {
+		if policy == nil {
+			continue
+		}
+		for {
+			key, hasKey := policy.Evict()
+			if !hasKey {
+				break
+			}
+			// pinned keys are never candidates, skip and retry
+			if _, pinned := c.pinned[key]; pinned {
+				continue
+			}
+			if skip == nil || !skip(key) {
+				return key, true
+			}
+		}
+	}
+
+	var zero K
+	return zero, false
+}
+
+// explicit delete, also drops key's dependency edges. Expired entries
+// count as already gone (assumes lock held)
+func (c *cache[K, V]) deleteLocked(key K) bool {
+	c.unlinkLocked(key)
+	return c.invalidateLocked(key)
+}
+
+// removes a live key, its dependency edges stay for the next value (assumes lock held)
+func (c *cache[K, V]) invalidateLocked(key K) bool {
+	if _, exists := c.lookupLocked(key); !exists {
+		return false
+	}
+	return c.removeLocked(key, ReasonDeleted)
+}
+
+// removes key from storage and policy (assumes lock held)
+func (c *cache[K, V]) removeLocked(key K, reason RemovalReason) bool {
+	if _, exists := c.storage.Peek(key); !exists {
+		return false
+	}
+
+	c.policyFor(key).Remove(key)
+	c.dropLocked(key, reason)
+	return true
+}
+
+// removes key and its metadata, policy tracking must already be gone (assumes lock held)
+func (c *cache[K, V]) dropLocked(key K, reason RemovalReason) {
+	item, exists := c.storage.Peek(key)
+	delete(c.pinned, key)
+	delete(c.priorities, key)
+	c.untagLocked(key)
+	if !exists {
+		return
+	}
+
+	value := item.Value
+	demoted := false
+	if reason == ReasonEvicted && c.demote != nil {
+		// copy first, storage may recycle item on Delete
+		demoted = c.demote(key, &storage.Item[V]{Value: value, ExpiresAt: item.ExpiresAt, HasTTL: item.HasTTL})
+	}
+	c.storage.Delete(key)
+	c.namespaceRemove(key, reason)
+	c.scanRemove(key)
+	c.tombstoneLocked(key)
+	// a demoted entry is still cached, just in the other tier
+	if !demoted {
+		c.notifyRemoval(key, value, reason)
+	}
+
+	// evicted values are still valid, dependents stay
+	if reason == ReasonDeleted || reason == ReasonExpired {
+		c.cascadeLocked(key)
+	}
+}
+
+// records access with the policy, pinned keys stay untracked
+func (c *cache[K, V]) access(key K) {
+	if _, pinned := c.pinned[key]; pinned {
+		return
+	}
+	c.policyFor(key).Access(key)
+}
+
+// drops an expired key found by a reader (assumes read lock held)
+func (c *cache[K, V]) expireFromRead(key K) {
+	if c.threadSafe {
+		c.mu.RUnlock()
+		c.mu.Lock()
+		defer func() {
+			c.mu.Unlock()
+			c.mu.RLock()
+		}()
+	}
+
+	// recheck, a writer may have replaced it meanwhile
+	if item, exists := c.storage.Peek(key); exists && item.IsExpired() {
+		c.removeLocked(key, ReasonExpired)
+	}
+}
+
+func (c *cache[K, V]) notifyRemoval(key K, value V, reason RemovalReason) {
+	if c.removal != nil {
+		c.removal.notify(key, value, reason)
+	}
+	// replacements are published as updates carrying the new value
+	if reason != ReasonReplaced {
+		c.events.publish(removalEventType(reason), key, value)
+	}
+}
+
+// Subscribe - streams cache mutations matching filter
+func (c *cache[K, V]) Subscribe(buffer int, filter EventFilter[K, V], delivery Delivery) *Subscription[K, V] {
+	return c.events.subscribe(buffer, filter, delivery)
+}
+
+// forwards a change to the backing store (assumes lock held)
+func (c *cache[K, V]) write(op WriteOp[K, V]) error {
+	if c.writer == nil {
+		return nil
+	}
+	// after Close the queue is gone, write through instead of losing op
+	if c.behind != nil && c.behind.enqueue(op) {
+		return nil
+	}
+	return applyWriteOp(c.writer, op)
+}
+
+// batch writer for write-through mode, write-behind batches on flush instead
+func (c *cache[K, V]) batchWriter() (BatchWriter[K, V], bool) {
+	if c.behind != nil {
+		return nil, false
+	}
+	bw, ok := c.writer.(BatchWriter[K, V])
+	return bw, ok
+}
+
+// stops bg cleanup and drains pending writes
 func (c *cache[K, V]) Close() {
-	if c.cleanupTicker != nil {
-		close(c.stopCleanup)
+	c.closeOnce.Do(func() {
+		if c.cleanupTicker != nil {
+			close(c.stopCleanup)
+		}
+		if c.reads != nil {
+			close(c.reads.stop)
+			<-c.reads.done
+		}
+		if c.behind != nil {
+			c.behind.close()
+		}
+		if c.removal != nil {
+			c.removal.close()
+		}
+		c.events.close()
+	})
+}
+
+// removes keys without touching the backing store
+func (c *cache[K, V]) invalidate(keys []K) int {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	var count int
+	for _, key := range keys {
+		c.unlinkLocked(key)
+		if _, exists := c.lookupLocked(key); exists {
+			c.removeLocked(key, ReasonCleared)
+			count++
+		}
 	}
+	return count
 }
@@ -1,8 +1,15 @@
 package cache
 
 import (
+	"bytes"
+	"caching-lib/codec"
 	"caching-lib/eviction"
+	"caching-lib/hasher"
+	"caching-lib/storage"
+	"errors"
 	"fmt"
+	"runtime"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -277,6 +284,2403 @@ func TestCacheEvictionPolicies(t *testing.T) {
 	}
 }
 
+// records writes for backing store tests
+type recordingWriter struct {
+	mu      sync.Mutex
+	data    map[string]string
+	batches int
+	fail    bool
+}
+
+func newRecordingWriter() *recordingWriter {
+	return &recordingWriter{data: make(map[string]string)}
+}
+
+func (w *recordingWriter) Write(key string, value string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail {
+		return errors.New("write failed")
+	}
+	w.data[key] = value
+	return nil
+}
+
+func (w *recordingWriter) Delete(key string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail {
+		return errors.New("delete failed")
+	}
+	delete(w.data, key)
+	return nil
+}
+
+func (w *recordingWriter) get(key string) (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	v, ok := w.data[key]
+	return v, ok
+}
+
+// batch-capable writer
+type recordingBatchWriter struct {
+	*recordingWriter
+}
+
+func (w recordingBatchWriter) WriteBatch(ops []WriteOp[string, string]) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail {
+		return errors.New("batch failed")
+	}
+	w.batches++
+	for _, op := range ops {
+		if op.Delete {
+			delete(w.data, op.Key)
+		} else {
+			w.data[op.Key] = op.Value
+		}
+	}
+	return nil
+}
+
+func TestCacheWriteThrough(t *testing.T) {
+	w := newRecordingWriter()
+	c := New(
+		WithCapacity[string, string](5),
+		WithWriteThrough[string, string](w),
+	)
+	defer c.Close()
+
+	c.Set("key1", "value1")
+	if v, ok := w.get("key1"); !ok || v != "value1" {
+		t.Errorf("Expected value1 in backing store, got %v", v)
+	}
+
+	c.Delete("key1")
+	if _, ok := w.get("key1"); ok {
+		t.Error("Expected key1 to be deleted from backing store")
+	}
+
+	w.fail = true
+	if c.Set("key2", "value2") {
+		t.Error("Expected Set to fail when writer fails")
+	}
+	if c.Contains("key2") {
+		t.Error("Expected key2 not to be cached after failed write")
+	}
+
+	w.fail = false
+	c.Set("key3", "value3")
+	w.fail = true
+	if c.Delete("key3") {
+		t.Error("Expected Delete to fail when writer fails")
+	}
+	if !c.Contains("key3") {
+		t.Error("Expected key3 to stay cached after failed delete")
+	}
+}
+
+func TestCacheWriteThroughBatch(t *testing.T) {
+	w := recordingBatchWriter{newRecordingWriter()}
+	c := New(
+		WithCapacity[string, string](10),
+		WithWriteThrough[string, string](w),
+	)
+	defer c.Close()
+
+	count := c.SetBatch(map[string]string{"key1": "value1", "key2": "value2"})
+	if count != 2 {
+		t.Errorf("Expected 2 items set, got %d", count)
+	}
+	if w.batches != 1 {
+		t.Errorf("Expected 1 batch write, got %d", w.batches)
+	}
+
+	w.fail = true
+	if count := c.SetBatch(map[string]string{"key3": "value3"}); count != 0 {
+		t.Errorf("Expected 0 items set after failed batch, got %d", count)
+	}
+	if deleted := c.DeleteBatch([]string{"key1", "key2"}); deleted != 0 {
+		t.Errorf("Expected 0 items deleted after failed batch, got %d", deleted)
+	}
+}
+
+func TestCacheWriteBehind(t *testing.T) {
+	w := recordingBatchWriter{newRecordingWriter()}
+	c := New(
+		WithCapacity[string, string](10),
+		WithWriteBehind[string, string](w, time.Hour, 100),
+	)
+
+	c.Set("key1", "value1")
+	c.Set("key1", "value2")
+	c.Set("key2", "value2")
+	c.Delete("key2")
+
+	if _, ok := w.get("key1"); ok {
+		t.Error("Expected write to be queued, not written yet")
+	}
+
+	c.Close()
+
+	if v, ok := w.get("key1"); !ok || v != "value2" {
+		t.Errorf("Expected coalesced value2 after drain, got %v", v)
+	}
+	if _, ok := w.get("key2"); ok {
+		t.Error("Expected key2 delete to be flushed")
+	}
+	if w.batches != 1 {
+		t.Errorf("Expected 1 batch flush, got %d", w.batches)
+	}
+}
+
+func TestCacheWriteBehindBatchSize(t *testing.T) {
+	w := newRecordingWriter()
+	c := New(
+		WithCapacity[string, string](10),
+		WithWriteBehind[string, string](w, time.Hour, 2),
+	)
+	defer c.Close()
+
+	c.Set("key1", "value1")
+	c.Set("key2", "value2") // reaches batch size
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := w.get("key2"); ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("Expected flush once batch size was reached")
+}
+
+func TestCacheWriteBehindAfterClose(t *testing.T) {
+	w := recordingBatchWriter{newRecordingWriter()}
+	c := New(
+		WithCapacity[string, string](10),
+		WithWriteBehind[string, string](w, time.Hour, 100),
+	)
+	c.Close()
+
+	if !c.Set("key1", "value1") {
+		t.Fatal("Expected Set after Close to succeed")
+	}
+	if v, ok := w.get("key1"); !ok || v != "value1" {
+		t.Errorf("Expected Set after Close to write through, got %v", v)
+	}
+
+	w.fail = true
+	if c.Set("key2", "value2") {
+		t.Error("Expected Set after Close to fail with the writer")
+	}
+}
+
+func TestCacheWriteBehindRetry(t *testing.T) {
+	w := recordingBatchWriter{newRecordingWriter()}
+	w.fail = true
+	failures := make(chan error, 100)
+	c := New(
+		WithCapacity[string, string](10),
+		WithWriteBehind[string, string](w, 5*time.Millisecond, 100),
+		WithWriteErrorHandler[string, string](func(err error) {
+			select {
+			case failures <- err:
+			default:
+			}
+		}),
+	)
+	defer c.Close()
+
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+	<-failures
+
+	// supersedes the failed op instead of being overwritten by its retry
+	c.Set("key1", "value3")
+	w.mu.Lock()
+	w.fail = false
+	w.mu.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		v1, _ := w.get("key1")
+		v2, _ := w.get("key2")
+		if v1 == "value3" && v2 == "value2" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("Expected the failed batch to be retried")
+}
+
+func TestCacheRemovalListener(t *testing.T) {
+	reasons := make(map[string]RemovalReason)
+	c := New(
+		WithCapacity[string, string](2),
+		WithOnRemoval(func(key string, value string, reason RemovalReason) {
+			reasons[key+"="+value] = reason
+		}),
+	)
+	defer c.Close()
+
+	c.Set("key1", "value1")
+	c.Set("key1", "value1b") // replaced
+	c.Set("key2", "value2")
+	c.Set("key3", "value3") // evicts key1
+	c.Delete("key2")
+	c.SetWithTTL("key4", "value4", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	c.Get("key4") // expired
+	c.Clear()     // key3
+
+	expected := map[string]RemovalReason{
+		"key1=value1":  ReasonReplaced,
+		"key1=value1b": ReasonEvicted,
+		"key2=value2":  ReasonDeleted,
+		"key4=value4":  ReasonExpired,
+		"key3=value3":  ReasonCleared,
+	}
+	for k, want := range expected {
+		if got, ok := reasons[k]; !ok || got != want {
+			t.Errorf("Expected %s to be removed as %v, got %v (seen %v)", k, want, got, ok)
+		}
+	}
+	if len(reasons) != len(expected) {
+		t.Errorf("Expected %d removals, got %d: %v", len(expected), len(reasons), reasons)
+	}
+}
+
+func TestCacheRemovalListenerCleanup(t *testing.T) {
+	var expired []string
+	c := New(
+		WithCapacity[string, string](5),
+		WithOnRemoval(func(key string, value string, reason RemovalReason) {
+			if reason == ReasonExpired {
+				expired = append(expired, key)
+			}
+		}),
+	).(*cache[string, string])
+	defer c.Close()
+
+	c.SetWithTTL("key1", "value1", time.Millisecond)
+	c.Set("key2", "value2")
+	time.Sleep(5 * time.Millisecond)
+	c.cleanup()
+
+	if len(expired) != 1 || expired[0] != "key1" {
+		t.Errorf("Expected key1 to expire on cleanup, got %v", expired)
+	}
+	if c.policies[PriorityNormal].Size() != 1 {
+		t.Errorf("Expected expired key to leave the policy, got size %d", c.policies[PriorityNormal].Size())
+	}
+}
+
+func TestCacheAsyncRemovalListener(t *testing.T) {
+	var c Cache[string, string]
+	removed := make(chan string, 10)
+	c = New(
+		WithCapacity[string, string](5),
+		WithAsyncRemoval[string, string](true),
+		WithOnRemoval(func(key string, value string, reason RemovalReason) {
+			// safe to call back into the cache when async
+			c.Contains(key)
+			removed <- key
+		}),
+	)
+
+	c.Set("key1", "value1")
+	c.Delete("key1")
+	c.Close()
+
+	select {
+	case key := <-removed:
+		if key != "key1" {
+			t.Errorf("Expected key1, got %s", key)
+		}
+	default:
+		t.Error("Expected removal to be delivered by Close")
+	}
+}
+
+func TestCacheSubscribe(t *testing.T) {
+	c := New(WithCapacity[string, string](2))
+	defer c.Close()
+
+	sub := c.Subscribe(16, nil, BlockOnFull)
+
+	c.Set("key1", "value1")
+	c.Set("key1", "value2")
+	c.Set("key2", "value2")
+	c.Set("key3", "value3") // evicts key1
+	c.Delete("key2")
+
+	expected := []struct {
+		typ EventType
+		key string
+	}{
+		{EventSet, "key1"},
+		{EventUpdate, "key1"},
+		{EventSet, "key2"},
+		{EventEvict, "key1"},
+		{EventSet, "key3"},
+		{EventDelete, "key2"},
+	}
+
+	for i, want := range expected {
+		select {
+		case ev := <-sub.C():
+			if ev.Type != want.typ || ev.Key != want.key {
+				t.Errorf("Event %d: expected %v %s, got %v %s", i, want.typ, want.key, ev.Type, ev.Key)
+			}
+		default:
+			t.Fatalf("Event %d: expected %v %s, got nothing", i, want.typ, want.key)
+		}
+	}
+
+	sub.Close()
+	if _, ok := <-sub.C(); ok {
+		t.Error("Expected channel to be closed")
+	}
+}
+
+func TestCacheSubscribeFilterAndDrop(t *testing.T) {
+	c := New(WithCapacity[string, string](10))
+
+	deletes := c.Subscribe(10, func(ev Event[string, string]) bool {
+		return ev.Type == EventDelete
+	}, DropOnFull)
+	small := c.Subscribe(1, nil, DropOnFull)
+
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+	c.Delete("key1")
+
+	if small.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped events, got %d", small.Dropped())
+	}
+
+	c.Close()
+
+	var got []Event[string, string]
+	for ev := range deletes.Events() {
+		got = append(got, ev)
+	}
+	if len(got) != 1 || got[0].Key != "key1" || got[0].Value != "value1" {
+		t.Errorf("Expected single delete of key1, got %v", got)
+	}
+}
+
+func TestCachePinned(t *testing.T) {
+	c := New(WithCapacity[string, string](2))
+	defer c.Close()
+
+	c.SetPinned("config", "value", 0)
+	c.Set("key1", "value1")
+	c.Set("key2", "value2") // evicts key1, not config
+	c.Set("key3", "value3") // evicts key2
+
+	if _, ok := c.Get("config"); !ok {
+		t.Error("Expected pinned key to survive eviction")
+	}
+	if _, ok := c.Get("key1"); ok {
+		t.Error("Expected key1 to be evicted")
+	}
+
+	stats := c.Stats()
+	if stats.Pinned != 1 {
+		t.Errorf("Expected 1 pinned entry, got %d", stats.Pinned)
+	}
+
+	if !c.Unpin("config") {
+		t.Error("Expected Unpin to succeed")
+	}
+	if c.Unpin("config") {
+		t.Error("Expected second Unpin to report not pinned")
+	}
+
+	c.Get("key3")
+	c.Set("key4", "value4") // config is least recently used now
+	if _, ok := c.Get("config"); ok {
+		t.Error("Expected unpinned key to be evictable")
+	}
+}
+
+func TestCachePinnedDeleteAndTTL(t *testing.T) {
+	c := New(WithCapacity[string, string](5))
+	defer c.Close()
+
+	c.SetPinned("key1", "value1", 0)
+	if !c.Delete("key1") {
+		t.Error("Expected pinned key to be deletable")
+	}
+	if c.Stats().Pinned != 0 {
+		t.Error("Expected delete to clear the pin")
+	}
+
+	c.SetPinned("key2", "value2", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get("key2"); ok {
+		t.Error("Expected pinned key to expire")
+	}
+	if c.Stats().Pinned != 0 {
+		t.Error("Expected expiry to clear the pin")
+	}
+}
+
+func TestCachePriorityEviction(t *testing.T) {
+	c := New(WithCapacity[string, string](3))
+	defer c.Close()
+
+	c.SetWithPriority("aggregate", "expensive", 0, PriorityHigh)
+	c.Set("lookup1", "cheap")
+	c.SetWithPriority("lookup2", "cheaper", 0, PriorityLow)
+
+	c.Set("key4", "value4") // low class first: lookup2
+	if _, ok := c.Get("lookup2"); ok {
+		t.Error("Expected low priority key to be evicted first")
+	}
+
+	c.Set("key5", "value5") // normal class next: lookup1
+	if _, ok := c.Get("lookup1"); ok {
+		t.Error("Expected normal priority key to be evicted before high")
+	}
+	if _, ok := c.Get("aggregate"); !ok {
+		t.Error("Expected high priority key to survive")
+	}
+}
+
+func TestCachePriorityPolicies(t *testing.T) {
+	c := New(
+		WithCapacity[string, string](2),
+		WithPriorityPolicy[string, string](PriorityLow, eviction.NewFIFO[string](2)),
+	)
+	defer c.Close()
+
+	c.SetWithPriority("key1", "value1", 0, PriorityLow)
+	c.SetWithPriority("key2", "value2", 0, PriorityLow)
+	c.Get("key1") // no effect under FIFO
+
+	c.Set("key3", "value3")
+	if _, ok := c.Get("key1"); ok {
+		t.Error("Expected FIFO low class to evict key1")
+	}
+
+	// moving a key between classes
+	c.SetWithPriority("key2", "value2", 0, PriorityHigh)
+	c.Set("key4", "value4") // evicts key3 from normal class
+	if _, ok := c.Get("key2"); !ok {
+		t.Error("Expected key2 to move to the high class")
+	}
+	if _, ok := c.Get("key3"); ok {
+		t.Error("Expected key3 to be evicted")
+	}
+}
+
+func TestCacheConditionalOperations(t *testing.T) {
+	c := New(WithCapacity[string, string](5))
+	defer c.Close()
+
+	if actual, loaded, err := c.GetOrSet("key1", "value1"); loaded || actual != "value1" || err != nil {
+		t.Errorf("Expected value1 to be stored, got %s (loaded %v, %v)", actual, loaded, err)
+	}
+	if actual, loaded, _ := c.GetOrSet("key1", "other"); !loaded || actual != "value1" {
+		t.Errorf("Expected value1 to be loaded, got %s (loaded %v)", actual, loaded)
+	}
+
+	if c.SetIfAbsent("key1", "other") {
+		t.Error("Expected SetIfAbsent to fail for existing key")
+	}
+	if !c.SetIfAbsent("key2", "value2") {
+		t.Error("Expected SetIfAbsent to store missing key")
+	}
+
+	if c.Replace("missing", "value") {
+		t.Error("Expected Replace to fail for missing key")
+	}
+	if !c.Replace("key2", "value2b") {
+		t.Error("Expected Replace to update existing key")
+	}
+
+	if c.CompareAndSwap("key2", "wrong", "value2c", nil) {
+		t.Error("Expected CompareAndSwap to fail on mismatch")
+	}
+	if !c.CompareAndSwap("key2", "value2b", "value2c", nil) {
+		t.Error("Expected CompareAndSwap to succeed on match")
+	}
+	if val, _ := c.Get("key2"); val != "value2c" {
+		t.Errorf("Expected value2c, got %s", val)
+	}
+
+	if c.CompareAndDelete("key2", "wrong", nil) {
+		t.Error("Expected CompareAndDelete to fail on mismatch")
+	}
+	if !c.CompareAndDelete("key2", "value2c", func(a, b string) bool { return a == b }) {
+		t.Error("Expected CompareAndDelete to succeed on match")
+	}
+	if c.Contains("key2") {
+		t.Error("Expected key2 to be deleted")
+	}
+}
+
+func TestCacheCompute(t *testing.T) {
+	c := New(WithCapacity[string, int](2))
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				c.Compute("counter", func(old int, exists bool) (int, ComputeAction) {
+					return old + 1, ComputeSet
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if val, _ := c.Get("counter"); val != 1000 {
+		t.Errorf("Expected 1000 after concurrent computes, got %d", val)
+	}
+
+	if val, ok := c.Compute("counter", func(old int, exists bool) (int, ComputeAction) {
+		return 0, ComputeNoop
+	}); !ok || val != 1000 {
+		t.Errorf("Expected noop to keep 1000, got %d", val)
+	}
+
+	if _, ok := c.Compute("counter", func(old int, exists bool) (int, ComputeAction) {
+		return 0, ComputeDelete
+	}); ok || c.Contains("counter") {
+		t.Error("Expected compute delete to remove the key")
+	}
+
+	// inserts honor eviction
+	c.Set("key1", 1)
+	c.Set("key2", 2)
+	c.Compute("key3", func(old int, exists bool) (int, ComputeAction) {
+		return 3, ComputeSet
+	})
+	if c.Size() != 2 {
+		t.Errorf("Expected size 2 after compute insert, got %d", c.Size())
+	}
+	if _, ok := c.Get("key1"); ok {
+		t.Error("Expected key1 to be evicted by compute insert")
+	}
+}
+
+func TestCounterCache(t *testing.T) {
+	c := NewCounterCache(WithCapacity[string, int64](10))
+	defer c.Close()
+
+	if val := c.Increment("requests", 5); val != 5 {
+		t.Errorf("Expected 5 on create, got %d", val)
+	}
+	if val := c.Decrement("requests", 2); val != 3 {
+		t.Errorf("Expected 3 after decrement, got %d", val)
+	}
+	if val := c.Decrement("missing", 1); val != -1 {
+		t.Errorf("Expected -1 for new key, got %d", val)
+	}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				c.Increment("concurrent", 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if val, _ := c.Get("concurrent"); val != 1000 {
+		t.Errorf("Expected 1000 after concurrent increments, got %d", val)
+	}
+
+	unsigned := NewCounterCache(WithCapacity[string, uint32](10))
+	unsigned.Increment("key", 5)
+	if val := unsigned.Decrement("key", 3); val != 2 {
+		t.Errorf("Expected 2 for unsigned counter, got %d", val)
+	}
+
+	// unsigned counters stop at zero instead of wrapping
+	uints := NewCounterCache(WithCapacity[string, uint](10))
+	defer uints.Close()
+	if val := uints.Decrement("missing", 3); val != 0 {
+		t.Errorf("Expected an absent unsigned key to start at 0, got %d", val)
+	}
+	uints.Increment("key", 2)
+	if val := uints.Decrement("key", 5); val != 0 {
+		t.Errorf("Expected unsigned decrement to clamp at 0, got %d", val)
+	}
+	if val, _ := uints.Get("key"); val != 0 {
+		t.Errorf("Expected 0 stored, got %d", val)
+	}
+}
+
+func TestCounterCacheTTL(t *testing.T) {
+	c := NewCounterCache(WithCapacity[string, int](10))
+	defer c.Close()
+
+	c.IncrementWithTTL("window", 1, 50*time.Millisecond, false)
+	time.Sleep(30 * time.Millisecond)
+	c.Increment("window", 1) // keeps original expiry
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := c.Get("window"); ok {
+		t.Error("Expected increment not to extend the TTL")
+	}
+
+	c.IncrementWithTTL("sliding", 1, 50*time.Millisecond, false)
+	time.Sleep(30 * time.Millisecond)
+	c.IncrementWithTTL("sliding", 1, 50*time.Millisecond, true)
+	time.Sleep(30 * time.Millisecond)
+
+	if val, ok := c.Get("sliding"); !ok || val != 2 {
+		t.Errorf("Expected reset TTL to keep counter alive with 2, got %d (%v)", val, ok)
+	}
+}
+
+func TestCachePeekAndTouch(t *testing.T) {
+	c := New(WithCapacity[string, string](2))
+	defer c.Close()
+
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+
+	// Peek doesn't count or reorder
+	if val, ok := c.Peek("key1"); !ok || val != "value1" {
+		t.Errorf("Expected value1, got %s", val)
+	}
+	if stats := c.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("Expected no stats from Peek, got %d hits %d misses", stats.Hits, stats.Misses)
+	}
+
+	c.Set("key3", "value3") // key1 still least recent
+	if _, ok := c.Peek("key1"); ok {
+		t.Error("Expected key1 to be evicted despite Peek")
+	}
+
+	if c.Touch("missing") {
+		t.Error("Expected Touch to fail for missing key")
+	}
+	c.Touch("key2")
+	c.Set("key4", "value4") // key3 least recent after touching key2
+	if _, ok := c.Peek("key2"); !ok {
+		t.Error("Expected touched key2 to survive")
+	}
+	if _, ok := c.Peek("key3"); ok {
+		t.Error("Expected key3 to be evicted")
+	}
+}
+
+func TestCacheExpireAndPersist(t *testing.T) {
+	c := New(WithCapacity[string, string](5))
+	defer c.Close()
+
+	c.Set("key1", "value1")
+	if _, expiresAt, ok := c.GetWithExpiry("key1"); !ok || !expiresAt.IsZero() {
+		t.Errorf("Expected no expiry, got %v", expiresAt)
+	}
+
+	before := time.Now()
+	if !c.Expire("key1", time.Hour) {
+		t.Error("Expected Expire to succeed")
+	}
+	val, expiresAt, ok := c.GetWithExpiry("key1")
+	if !ok || val != "value1" {
+		t.Errorf("Expected value1 to be kept, got %s", val)
+	}
+	if expiresAt.Before(before.Add(time.Hour)) || expiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("Expected expiry about an hour out, got %v", expiresAt)
+	}
+
+	if !c.Persist("key1") {
+		t.Error("Expected Persist to succeed")
+	}
+	if _, expiresAt, _ := c.GetWithExpiry("key1"); !expiresAt.IsZero() {
+		t.Errorf("Expected TTL to be cleared, got %v", expiresAt)
+	}
+
+	c.Expire("key1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if c.Contains("key1") {
+		t.Error("Expected key1 to expire")
+	}
+
+	c.Set("key2", "value2")
+	if !c.Expire("key2", 0) || c.Contains("key2") {
+		t.Error("Expected zero TTL to expire key2 immediately")
+	}
+	if c.Expire("missing", time.Hour) || c.Persist("missing") {
+		t.Error("Expected Expire/Persist to fail for missing key")
+	}
+}
+
+func TestCacheIterators(t *testing.T) {
+	c := New(WithCapacity[string, string](5))
+	defer c.Close()
+
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+	c.SetWithTTL("key3", "value3", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	entries := make(map[string]string)
+	for key, value := range c.All() {
+		entries[key] = value
+	}
+	if len(entries) != 2 || entries["key1"] != "value1" {
+		t.Errorf("Expected 2 live entries, got %v", entries)
+	}
+
+	var keys, values int
+	for range c.KeysSeq() {
+		keys++
+	}
+	for range c.ValuesSeq() {
+		values++
+	}
+	if keys != 2 || values != 2 {
+		t.Errorf("Expected 2 keys and values, got %d and %d", keys, values)
+	}
+}
+
+func TestCacheAllOrdered(t *testing.T) {
+	c := New(WithCapacity[string, string](5))
+	defer c.Close()
+
+	c.Set("key1", "value1")
+	c.Set("key2", "value2")
+	c.SetWithPriority("key3", "value3", 0, PriorityLow)
+	c.SetWithPriority("key4", "value4", 0, PriorityHigh)
+	c.SetPinned("key5", "value5", 0)
+	c.Get("key1")
+
+	var keys []string
+	for key := range c.AllOrdered() {
+		keys = append(keys, key)
+	}
+
+	expected := []string{"key5", "key4", "key1", "key2", "key3"}
+	if fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, keys)
+	}
+}
+
+func TestCacheTags(t *testing.T) {
+	c := New(WithCapacity[string, string](10))
+	defer c.Close()
+
+	c.SetWithTags("user:1:profile", "p1", 0, "user:1", "tenant:a")
+	c.SetWithTags("user:1:settings", "s1", 0, "user:1")
+	c.SetWithTags("user:2:profile", "p2", 0, "user:2", "tenant:a")
+	c.Set("user:1:settings", "s1b") // keeps tags
+
+	if count := c.InvalidateTag("user:1"); count != 2 {
+		t.Errorf("Expected 2 keys invalidated, got %d", count)
+	}
+	if c.Contains("user:1:profile") || c.Contains("user:1:settings") {
+		t.Error("Expected user:1 keys to be invalidated")
+	}
+	if !c.Contains("user:2:profile") {
+		t.Error("Expected user:2 key to remain")
+	}
+
+	if count := c.InvalidateTag("tenant:a"); count != 1 {
+		t.Errorf("Expected 1 key invalidated, got %d", count)
+	}
+	if count := c.InvalidateTag("unknown"); count != 0 {
+		t.Errorf("Expected 0 keys for unknown tag, got %d", count)
+	}
+}
+
+func TestCacheTagIndexCleanup(t *testing.T) {
+	c := New(WithCapacity[string, string](2)).(*cache[string, string])
+	defer c.Close()
+
+	c.SetWithTags("key1", "value1", 0, "a")
+	c.SetWithTags("key2", "value2", time.Millisecond, "a", "b")
+	c.SetWithTags("key3", "value3", 0, "c")
+	c.Delete("key3")
+	time.Sleep(5 * time.Millisecond)
+	c.cleanup()
+	c.Set("key4", "value4")
+	c.Set("key5", "value5") // evicts key1
+
+	if len(c.tagKeys) != 0 || len(c.keyTags) != 0 {
+		t.Errorf("Expected empty tag index, got %v / %v", c.tagKeys, c.keyTags)
+	}
+}
+
+func TestCacheDependencies(t *testing.T) {
+	c := New(WithCapacity[string, string](10))
+	defer c.Close()
+
+	c.Set("product:1", "record")
+	c.Set("product:2", "record")
+	c.Set("page:home", "html")
+	c.Set("page:sitemap", "xml")
+
+	if err := c.DependsOn("page:home", "product:1", "product:2"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := c.DependsOn("page:sitemap", "page:home"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// overwrite cascades through the chain
+	c.Set("product:1", "updated")
+	if c.Contains("page:home") || c.Contains("page:sitemap") {
+		t.Error("Expected overwrite to invalidate dependents transitively")
+	}
+
+	c.Set("page:home", "html")
+	c.DependsOn("page:home", "product:2")
+	c.Delete("product:2")
+	if c.Contains("page:home") {
+		t.Error("Expected delete to invalidate dependent")
+	}
+
+	c.SetWithTTL("product:3", "record", time.Millisecond)
+	c.Set("page:product3", "html")
+	c.DependsOn("page:product3", "product:3")
+	time.Sleep(5 * time.Millisecond)
+	c.Get("product:3")
+	if c.Contains("page:product3") {
+		t.Error("Expected expiry to invalidate dependent")
+	}
+}
+
+func TestCacheDependencyCycles(t *testing.T) {
+	c := New(WithCapacity[string, string](10))
+	defer c.Close()
+	c.SetBatch(map[string]string{"a": "a", "b": "b", "c": "c"})
+
+	if err := c.DependsOn("a", "a"); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("Expected self dependency to be a cycle, got %v", err)
+	}
+
+	c.DependsOn("a", "b")
+	c.DependsOn("b", "c")
+	if err := c.DependsOn("c", "a"); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("Expected cycle error, got %v", err)
+	}
+}
+
+func TestCacheDependencyRepeatedInvalidation(t *testing.T) {
+	c := New(WithCapacity[string, string](10))
+	defer c.Close()
+
+	if err := c.DependsOn("page", "product"); !errors.Is(err, ErrKeyNotCached) {
+		t.Errorf("Expected ErrKeyNotCached for an uncached key, got %v", err)
+	}
+
+	c.Set("product", "v1")
+	c.Set("page", "html")
+	c.DependsOn("page", "product")
+
+	for i := range 3 {
+		c.Set("product", fmt.Sprintf("v%d", i+2))
+		if c.Contains("page") {
+			t.Fatalf("Expected write %d to invalidate page", i+1)
+		}
+		c.Set("page", "html")
+	}
+
+	// an explicit delete forgets the edges
+	c.Delete("page")
+	c.Set("product", "v9")
+	c.Set("page", "html")
+	c.Set("product", "v10")
+	if !c.Contains("page") {
+		t.Error("Expected explicit delete to drop page's dependencies")
+	}
+
+	c.DependsOn("page", "product")
+	c.RemoveDependency("page", "product")
+	c.Set("product", "v11")
+	if !c.Contains("page") {
+		t.Error("Expected RemoveDependency to drop the edge")
+	}
+	if len(c.(*cache[string, string]).dependencies) != 0 {
+		t.Error("Expected no dependency edges left")
+	}
+}
+
+func TestCacheDependencyDepthAndEviction(t *testing.T) {
+	c := New(
+		WithCapacity[string, string](4),
+		WithMaxCascadeDepth[string, string](2),
+	)
+	defer c.Close()
+
+	// d depends on c depends on b depends on a
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.Set(key, key)
+	}
+	c.DependsOn("b", "a")
+	c.DependsOn("c", "b")
+	c.DependsOn("d", "c")
+
+	c.Delete("a")
+	if c.Contains("b") || c.Contains("c") {
+		t.Error("Expected cascade to reach two levels")
+	}
+	if !c.Contains("d") {
+		t.Error("Expected cascade to stop at max depth")
+	}
+
+	// eviction keeps dependents
+	c.Set("x", "x")
+	c.Set("y", "y")
+	c.DependsOn("y", "x")
+	c.Get("y")
+	c.Get("d")
+	c.Set("z", "z")
+	c.Set("w", "w") // evicts x
+	if c.Contains("x") {
+		t.Fatal("Expected x to be evicted")
+	}
+	if !c.Contains("y") {
+		t.Error("Expected eviction not to cascade")
+	}
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	w := newRecordingWriter()
+	parent := New(
+		WithCapacity[string, string](10),
+		WithWriteThrough[string, string](w),
+	)
+	defer parent.Close()
+
+	users := Namespace(parent, "users:")
+	orders := Namespace(parent, "orders:")
+	defer users.Close()
+	defer orders.Close()
+
+	users.Set("1", "alice")
+	orders.Set("1", "order")
+
+	if val, _ := users.Get("1"); val != "alice" {
+		t.Errorf("Expected alice, got %s", val)
+	}
+	if val, _ := orders.Get("1"); val != "order" {
+		t.Errorf("Expected order, got %s", val)
+	}
+	if val, _ := parent.Get("users:1"); val != "alice" {
+		t.Errorf("Expected prefixed key in parent, got %s", val)
+	}
+
+	if keys := users.Keys(); len(keys) != 1 || keys[0] != "1" {
+		t.Errorf("Expected [1], got %v", keys)
+	}
+
+	users.ClearNamespace()
+	if users.Size() != 0 {
+		t.Errorf("Expected empty namespace, got %d", users.Size())
+	}
+	if !orders.Contains("1") {
+		t.Error("Expected other namespace to be untouched")
+	}
+	if _, ok := w.get("users:1"); !ok {
+		t.Error("Expected ClearNamespace to leave the backing store alone")
+	}
+}
+
+func TestNamespaceStats(t *testing.T) {
+	parent := New(WithCapacity[string, string](2))
+	defer parent.Close()
+
+	users := Namespace(parent, "users:")
+	defer users.Close()
+
+	users.Set("1", "alice")
+	users.Get("1")
+	users.Get("2")
+	parent.Get("other")
+
+	parent.Set("a", "a")
+	parent.Set("b", "b") // evicts users:1
+
+	stats := users.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %d and %d", stats.Hits, stats.Misses)
+	}
+	if stats.Evictions != 1 {
+		t.Errorf("Expected 1 eviction, got %d", stats.Evictions)
+	}
+	if stats.Size != 0 || stats.Capacity != 2 {
+		t.Errorf("Expected size 0 with shared capacity 2, got %d/%d", stats.Size, stats.Capacity)
+	}
+}
+
+func TestNamespaceOverlappingNames(t *testing.T) {
+	parent := New(WithCapacity[string, int](100))
+	defer parent.Close()
+
+	a := Namespace(parent, "a")
+	ab := Namespace(parent, "ab")
+	colon := Namespace(parent, "a:b")
+	defer a.Close()
+	defer ab.Close()
+	defer colon.Close()
+
+	a.Set("b:x", 1)
+	ab.Set("x", 2)
+	colon.Set("x", 3)
+
+	if a.Size() != 1 || ab.Size() != 1 || colon.Size() != 1 {
+		t.Errorf("Expected one key each, got %v %v %v", a.Keys(), ab.Keys(), colon.Keys())
+	}
+
+	a.ClearNamespace()
+	if v, ok := ab.Get("x"); !ok || v != 2 {
+		t.Error("Expected clearing a to leave ab alone")
+	}
+	if v, ok := colon.Get("x"); !ok || v != 3 {
+		t.Error("Expected clearing a to leave a:b alone")
+	}
+	if a.Size() != 0 || parent.Size() != 2 {
+		t.Errorf("Expected only a's key gone, parent has %v", parent.Keys())
+	}
+}
+
+func TestNamespaceNested(t *testing.T) {
+	parent := New(WithCapacity[string, int](4))
+	defer parent.Close()
+
+	users := Namespace(parent, "users")
+	admins := Namespace(users, "admins")
+	defer users.Close()
+	defer admins.Close()
+
+	admins.Set("root", 1)
+	users.Set("alice", 2)
+	if _, ok := parent.Peek("users:admins:root"); !ok {
+		t.Errorf("Expected nested prefixes, got %v", parent.Keys())
+	}
+	if admins.Size() != 1 || users.Size() != 2 {
+		t.Errorf("Expected 1 admin within 2 users, got %d and %d", admins.Size(), users.Size())
+	}
+	if keys := admins.Keys(); len(keys) != 1 || keys[0] != "root" {
+		t.Errorf("Expected local admin keys, got %v", keys)
+	}
+
+	// evicting users:admins:root counts for both views
+	parent.Set("x", 0)
+	parent.Set("y", 0)
+	parent.Set("z", 0)
+	if admins.Stats().Evictions != 1 || users.Stats().Evictions != 1 {
+		t.Errorf("Expected eviction counted per namespace, got %d and %d",
+			admins.Stats().Evictions, users.Stats().Evictions)
+	}
+
+	parent.Delete("x")
+	admins.Set("root", 1)
+	admins.ClearNamespace()
+	if admins.Size() != 0 || users.Size() != 1 {
+		t.Errorf("Expected nested clear to stay nested, users has %v", users.Keys())
+	}
+}
+
+func TestNamespaceNestedClearKeepsStore(t *testing.T) {
+	w := newRecordingWriter()
+	parent := New(WithWriteThrough[string, string](w))
+	defer parent.Close()
+
+	users := Namespace(parent, "users")
+	admins := Namespace(users, "admins")
+	defer users.Close()
+	defer admins.Close()
+
+	admins.Set("root", "r")
+	admins.ClearNamespace()
+	if admins.Contains("root") {
+		t.Error("Expected root cleared from the nested namespace")
+	}
+	if v, ok := w.get("users:admins:root"); !ok || v != "r" {
+		t.Errorf("Expected the writer to keep a cleared nested key, got %q, %v", v, ok)
+	}
+}
+
+func TestNamespaceNoParentSubscription(t *testing.T) {
+	parent := New[string, int]().(*cache[string, int])
+	defer parent.Close()
+
+	// views that are never closed
+	func() {
+		for i := range 10 {
+			Namespace[int](parent, fmt.Sprintf("ns%d", i)).Set("k", i)
+		}
+	}()
+	if parent.events.active() {
+		t.Error("Expected views not to keep the parent's event hub busy")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		runtime.GC()
+		parent.mu.RLock()
+		n := len(parent.namespaces)
+		parent.mu.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected collected views to unregister, %d left", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNamespaceSubscribeAndTags(t *testing.T) {
+	parent := New(WithCapacity[string, string](10))
+	defer parent.Close()
+
+	users := Namespace(parent, "users:")
+	defer users.Close()
+
+	sub := users.Subscribe(10, nil, BlockOnFull)
+	parent.Set("other", "value")
+	users.SetWithTags("1", "alice", 0, "team")
+	parent.SetWithTags("team-member", "bob", 0, "team")
+
+	select {
+	case ev := <-sub.C():
+		if ev.Key != "1" || ev.Type != EventSet {
+			t.Errorf("Expected set of 1, got %v %s", ev.Type, ev.Key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected namespaced event")
+	}
+
+	if count := users.InvalidateTag("team"); count != 1 {
+		t.Errorf("Expected namespaced tag to remove 1 key, got %d", count)
+	}
+	if !parent.Contains("team-member") {
+		t.Error("Expected parent tag to be untouched")
+	}
+}
+
+func TestNamespaceSlowSubscriber(t *testing.T) {
+	parent := New(WithCapacity[string, int](100))
+	defer parent.Close()
+	users := Namespace(parent, "users")
+	defer users.Close()
+
+	// never read, a blocking view subscriber mustn't stall the parent
+	users.Subscribe(1, nil, BlockOnFull)
+	done := make(chan struct{})
+	go func() {
+		for i := range 5000 {
+			users.Set(fmt.Sprint(i), i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected parent writes to go on past a stuck view subscriber")
+	}
+}
+
+func TestCacheOrderedQueries(t *testing.T) {
+	c := New(
+		WithCapacity[string, int](100),
+		WithStorage[string, int](storage.NewOrderedStorage[string, int]()),
+	).(OrderedCache[string, int])
+	defer c.Close()
+
+	for i, key := range []string{"user:3", "order:1", "user:1", "user:2", "users", "order:2"} {
+		c.Set(key, i)
+	}
+
+	var keys []string
+	seq, err := c.ScanPrefix("user:")
+	if err != nil {
+		t.Fatalf("Expected ScanPrefix to work, got %v", err)
+	}
+	for key := range seq {
+		keys = append(keys, key)
+	}
+	if fmt.Sprint(keys) != "[user:1 user:2 user:3]" {
+		t.Errorf("Expected sorted user: keys, got %v", keys)
+	}
+
+	keys = nil
+	seq, _ = c.Range("order:2", "user:2")
+	for key := range seq {
+		keys = append(keys, key)
+	}
+	if fmt.Sprint(keys) != "[order:2 user:1]" {
+		t.Errorf("Expected [order:2 user:1], got %v", keys)
+	}
+
+	count, err := c.DeletePrefix("order:")
+	if err != nil || count != 2 {
+		t.Errorf("Expected 2 keys deleted, got %d (%v)", count, err)
+	}
+	if c.Size() != 4 || c.Contains("order:1") {
+		t.Error("Expected order: keys to be gone")
+	}
+
+	users := Namespace(c, "user:")
+	if count, _ := users.DeletePrefix(""); count != 3 {
+		t.Errorf("Expected namespace to delete 3 keys, got %d", count)
+	}
+	if !c.Contains("users") {
+		t.Error("Expected key outside the namespace to stay")
+	}
+}
+
+func TestCacheOrderedQueriesUnsupported(t *testing.T) {
+	c := New(WithCapacity[string, int](10)).(OrderedCache[string, int])
+	if _, err := c.ScanPrefix("a"); !errors.Is(err, ErrUnorderedStorage) {
+		t.Errorf("Expected ErrUnorderedStorage, got %v", err)
+	}
+	if _, err := c.Range("a", "b"); !errors.Is(err, ErrUnorderedStorage) {
+		t.Errorf("Expected ErrUnorderedStorage, got %v", err)
+	}
+
+	ints := New(
+		WithCapacity[int, int](10),
+		WithStorage[int, int](storage.NewOrderedStorage[int, int]()),
+	).(OrderedCache[int, int])
+	if _, err := ints.DeletePrefix(1); !errors.Is(err, ErrNonStringKeys) {
+		t.Errorf("Expected ErrNonStringKeys, got %v", err)
+	}
+	if _, err := ints.Range(1, 5); err != nil {
+		t.Errorf("Expected Range on int keys to work, got %v", err)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "anything", true},
+		{"user:*", "user:42", true},
+		{"user:*", "order:42", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"*:*:end", "a:b:c:end", true},
+		{`key\*`, "key*", true},
+		{`key\*`, "keys", false},
+		{"", "", true},
+		{"", "x", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestCacheScan(t *testing.T) {
+	c := New(WithCapacity[string, int](1000)).(ScannableCache[string, int])
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprintf("user:%d", i), i)
+		c.Set(fmt.Sprintf("order:%d", i), i)
+	}
+
+	seen := make(map[string]int)
+	var cursor uint64
+	pages := 0
+	for {
+		keys, next, err := c.Scan(cursor, "user:*", 7)
+		if err != nil {
+			t.Fatalf("Expected Scan to work, got %v", err)
+		}
+		for _, key := range keys {
+			seen[key]++
+		}
+		// writes between pages must not break the scan
+		c.Set(fmt.Sprintf("order:new:%d", pages), pages)
+		pages++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	if len(seen) != 100 {
+		t.Errorf("Expected all 100 user keys, got %d", len(seen))
+	}
+	if pages < 100/7 {
+		t.Errorf("Expected the scan to be paged, got %d pages", pages)
+	}
+
+	deleted, err := c.DeleteMatching("order:[0-9]*")
+	if err != nil || deleted != 100 {
+		t.Errorf("Expected 100 keys deleted, got %d (%v)", deleted, err)
+	}
+	if !c.Contains("order:new:0") || !c.Contains("user:1") {
+		t.Error("Expected non-matching keys to stay")
+	}
+
+	users := Namespace(c, "user:")
+	keys, _, _ := users.Scan(0, "1?", 1000)
+	if len(keys) != 10 {
+		t.Errorf("Expected 10 namespaced keys, got %v", keys)
+	}
+
+	ints := New(WithCapacity[int, int](10)).(ScannableCache[int, int])
+	if _, _, err := ints.Scan(0, "*", 10); !errors.Is(err, ErrNonStringKeys) {
+		t.Errorf("Expected ErrNonStringKeys, got %v", err)
+	}
+}
+
+func TestCacheScanLarge(t *testing.T) {
+	c := New(WithCapacity[string, int](50000)).(ScannableCache[string, int])
+	defer c.Close()
+
+	for i := 0; i < 5000; i++ {
+		c.Set(fmt.Sprintf("key:%d", i), i)
+	}
+
+	seen := make(map[string]int)
+	var cursor uint64
+	pages := 0
+	for {
+		keys, next, err := c.Scan(cursor, "key:*", 100)
+		if err != nil {
+			t.Fatalf("Expected Scan to work, got %v", err)
+		}
+		for _, key := range keys {
+			seen[key]++
+		}
+		// grows the index mid-scan, old cursors must stay valid
+		for j := 0; j < 100; j++ {
+			c.Set(fmt.Sprintf("new:%d:%d", pages, j), j)
+		}
+		pages++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	if len(seen) != 5000 {
+		t.Errorf("Expected all 5000 keys, got %d", len(seen))
+	}
+	if pages < 50 || pages > 60 {
+		t.Errorf("Expected about 50 pages, got %d", pages)
+	}
+	added := pages * 100
+
+	// a selective pattern can return short pages but still finishes
+	var found []string
+	cursor, pages = 0, 0
+	for {
+		keys, next, _ := c.Scan(cursor, "key:4999", 10)
+		found = append(found, keys...)
+		pages++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if len(found) != 1 || pages < 2 {
+		t.Errorf("Expected one match over several pages, got %v in %d pages", found, pages)
+	}
+
+	deleted, err := c.DeleteMatching("new:*")
+	if err != nil || deleted != added || c.Size() != 5000 {
+		t.Errorf("Expected only key:* left, got %d keys after deleting %d (%v)", c.Size(), deleted, err)
+	}
+
+	c.Clear()
+	c.Set("key:after", 1)
+	if keys, _, _ := c.Scan(0, "*", 10); len(keys) != 1 {
+		t.Errorf("Expected the index reset by Clear, got %v", keys)
+	}
+}
+
+func TestCacheTxn(t *testing.T) {
+	w := recordingBatchWriter{newRecordingWriter()}
+	c := New(
+		WithCapacity[string, string](3),
+		WithWriteThrough[string, string](w),
+	)
+	defer c.Close()
+
+	c.Set("user:1", "alice")
+	c.Set("idx:alice", "user:1")
+
+	tx := c.Begin()
+	name, _ := tx.Get("user:1")
+	tx.Set("user:1", "alicia")
+	tx.Delete("idx:" + name)
+	tx.Set("idx:alicia", "user:1")
+	if v, ok := tx.Get("user:1"); !ok || v != "alicia" {
+		t.Errorf("Expected txn to read its own write, got %v", v)
+	}
+	if v, _ := c.Get("user:1"); v != "alice" {
+		t.Error("Expected buffered writes to stay invisible before Commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Expected commit to succeed, got %v", err)
+	}
+	if v, _ := c.Get("user:1"); v != "alicia" || c.Contains("idx:alice") || !c.Contains("idx:alicia") {
+		t.Error("Expected all writes applied")
+	}
+	if c.Stats().Evictions != 0 {
+		t.Error("Expected the delete to make room without evicting")
+	}
+	if w.batches != 1 {
+		t.Errorf("Expected one batch write, got %d", w.batches)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTxnDone) {
+		t.Errorf("Expected ErrTxnDone, got %v", err)
+	}
+}
+
+func TestCacheTxnUnbatched(t *testing.T) {
+	w := newRecordingWriter()
+	c := New(WithWriteThrough[string, string](w))
+	defer c.Close()
+
+	tx := c.Begin()
+	tx.Set("a", "1")
+	tx.Set("b", "2")
+	if err := tx.Commit(); !errors.Is(err, ErrTxnUnbatched) {
+		t.Fatalf("Expected ErrTxnUnbatched, got %v", err)
+	}
+	if _, ok := w.get("a"); ok || c.Contains("a") {
+		t.Error("Expected nothing written by a refused txn")
+	}
+}
+
+func TestCacheTxnConflict(t *testing.T) {
+	c := New(WithCapacity[string, int](10))
+	defer c.Close()
+
+	c.Set("balance", 10)
+
+	tx := c.Begin()
+	balance, _ := tx.Get("balance")
+	tx.Set("balance", balance+5)
+	tx.Set("log", 5)
+
+	// delete and re-create with the same value still counts as a change
+	c.Delete("balance")
+	c.Set("balance", 10)
+
+	if err := tx.Commit(); !errors.Is(err, ErrTxnConflict) {
+		t.Fatalf("Expected ErrTxnConflict, got %v", err)
+	}
+	if c.Contains("log") {
+		t.Error("Expected no writes applied on conflict")
+	}
+
+	tx = c.Begin()
+	tx.Watch("missing")
+	c.Set("missing", 1)
+	tx.Set("other", 1)
+	if err := tx.Commit(); !errors.Is(err, ErrTxnConflict) {
+		t.Errorf("Expected conflict on watched absent key, got %v", err)
+	}
+
+	// a watched absent key that comes and goes is still a change
+	tx = c.Begin()
+	tx.Watch("ghost")
+	c.Set("ghost", 1)
+	c.Delete("ghost")
+	tx.Set("other", 1)
+	if err := tx.Commit(); !errors.Is(err, ErrTxnConflict) {
+		t.Errorf("Expected conflict on a key set and deleted meanwhile, got %v", err)
+	}
+
+	tx = c.Begin()
+	tx.Watch("still-missing")
+	c.Set("unrelated", 1)
+	tx.Set("other", 1)
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Expected untouched absent key not to conflict, got %v", err)
+	}
+
+	users := Namespace(c, "users:")
+	tx = users.Begin()
+	tx.Set("1", 1)
+	if err := tx.Commit(); err != nil || !c.Contains("users:1") {
+		t.Errorf("Expected namespaced txn to write users:1, got %v", err)
+	}
+}
+
+func TestCacheTxnEviction(t *testing.T) {
+	var evicted []string
+	c := New(
+		WithCapacity[string, int](2),
+		WithOnRemoval(func(key string, _ int, reason RemovalReason) {
+			if reason == ReasonEvicted {
+				evicted = append(evicted, key)
+			}
+		}),
+	)
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	tx := c.Begin()
+	tx.Set("c", 3)
+	tx.Set("d", 4)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Size() != 2 || c.Stats().Evictions != 2 || len(evicted) != 2 {
+		t.Errorf("Expected 2 evictions, got size %d stats %d listener %v", c.Size(), c.Stats().Evictions, evicted)
+	}
+	if !c.Contains("c") || !c.Contains("d") {
+		t.Error("Expected txn writes to survive")
+	}
+}
+
+func TestCacheTxnCapacity(t *testing.T) {
+	// LIFO evicts the newest key, so sets applied one by one would evict each other
+	c := New(
+		WithCapacity[string, int](2),
+		WithEvictionPolicy[string, int](eviction.NewLIFO[string](2)),
+	)
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	tx := c.Begin()
+	tx.Set("c", 3)
+	tx.Set("d", 4)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Contains("c") || !c.Contains("d") || c.Stats().Evictions != 2 {
+		t.Errorf("Expected both txn writes kept over a and b, got %v", c.Keys())
+	}
+
+	tx = c.Begin()
+	tx.Set("x", 1)
+	tx.Set("y", 2)
+	tx.Set("z", 3)
+	if err := tx.Commit(); !errors.Is(err, ErrTxnTooLarge) {
+		t.Errorf("Expected ErrTxnTooLarge, got %v", err)
+	}
+	if c.Contains("x") || !c.Contains("c") {
+		t.Error("Expected nothing applied by an oversized txn")
+	}
+}
+
+func TestCacheTxnReadBuffer(t *testing.T) {
+	c := New(
+		WithCapacity[string, int](3),
+		WithReadBuffer[string, int](true),
+	)
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a")
+
+	// the buffered hit on a counts before the commit picks a victim
+	tx := c.Begin()
+	tx.Set("d", 4)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Contains("a") || c.Contains("b") {
+		t.Errorf("Expected b evicted and a kept, got keys %v", c.Keys())
+	}
+}
+
+func TestShardedCache(t *testing.T) {
+	c := NewSharded(6, WithCapacity[string, int](100))
+	defer c.Close()
+
+	shards := c.ShardStats()
+	if len(shards) != 8 {
+		t.Fatalf("Expected 6 shards rounded up to 8, got %d", len(shards))
+	}
+	capacity := 0
+	for _, stats := range shards {
+		if stats.Capacity < 12 || stats.Capacity > 13 {
+			t.Errorf("Expected capacity spread evenly, got %d", stats.Capacity)
+		}
+		capacity += stats.Capacity
+	}
+	if capacity != 100 {
+		t.Errorf("Expected total capacity 100, got %d", capacity)
+	}
+
+	// few enough keys that no shard overflows, wherever they hash
+	for i := 0; i < 20; i++ {
+		c.Set(fmt.Sprintf("key%d", i), i)
+	}
+	if v, ok := c.Get("key7"); !ok || v != 7 {
+		t.Errorf("Expected 7, got %v", v)
+	}
+	c.Get("missing")
+
+	stats := c.Stats()
+	if stats.Size != 20 || c.Size() != 20 || len(c.Keys()) != 20 {
+		t.Errorf("Expected 20 items, got %d", stats.Size)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 || stats.HitRatio != 0.5 {
+		t.Errorf("Expected aggregated hits/misses, got %+v", stats)
+	}
+
+	got := c.GetBatch([]string{"key1", "key2", "missing"})
+	if len(got) != 2 {
+		t.Errorf("Expected 2 batch hits, got %v", got)
+	}
+	if n := c.DeleteBatch([]string{"key1", "key2", "key3"}); n != 3 {
+		t.Errorf("Expected 3 deleted, got %d", n)
+	}
+
+	// overfilling every shard keeps the total within capacity
+	for i := 0; i < 1000; i++ {
+		c.Set(fmt.Sprintf("fill%d", i), i)
+	}
+	if c.Size() > 100 || c.Stats().Evictions == 0 {
+		t.Errorf("Expected evictions within capacity, got size %d", c.Size())
+	}
+
+	small := NewSharded(16, WithCapacity[int, int](3))
+	defer small.Close()
+	if n := len(small.ShardStats()); n != 2 {
+		t.Errorf("Expected shards capped by capacity, got %d", n)
+	}
+}
+
+func TestShardedCacheConcurrent(t *testing.T) {
+	c := NewSharded(0,
+		WithCapacity[int, int](1000),
+		WithShardPolicy[int, int](func(capacity int) eviction.Policy[int] {
+			return eviction.NewFIFOWithConfig[int](capacity, true)
+		}),
+	)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := g*1000 + i
+				c.Set(key, i)
+				c.Get(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if c.Size() > 1000 {
+		t.Errorf("Expected size within capacity, got %d", c.Size())
+	}
+}
+
+func TestShardedCacheTxnAndEvents(t *testing.T) {
+	w := recordingBatchWriter{newRecordingWriter()}
+	// the leading digit picks the shard
+	c := NewSharded(4,
+		WithCapacity[string, string](8),
+		WithWriteThrough[string, string](w),
+		WithHasher[string, string](hasher.Func[string](func(k string) uint64 {
+			return uint64(k[0] - '0')
+		})),
+	)
+	defer c.Close()
+	sub := c.Subscribe(10, nil, DropOnFull)
+
+	tx := c.Begin()
+	tx.Get("0a")
+	tx.Set("0a", "x")
+	tx.Set("1b", "y")
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Contains("0a") || !c.Contains("1b") || w.batches != 1 {
+		t.Errorf("Expected both shards written in one batch, got %v after %d batches", c.Keys(), w.batches)
+	}
+	for range 2 {
+		select {
+		case <-sub.C():
+		case <-time.After(time.Second):
+			t.Fatal("Expected an event from each shard")
+		}
+	}
+
+	tx = c.Begin()
+	tx.Get("1b")
+	tx.Set("2c", "z")
+	c.Set("1b", "changed")
+	if err := tx.Commit(); !errors.Is(err, ErrTxnConflict) || c.Contains("2c") {
+		t.Errorf("Expected a conflict on another shard to abort, got %v", err)
+	}
+
+	if err := c.DependsOn("0a", "1b"); !errors.Is(err, ErrCrossShard) {
+		t.Errorf("Expected ErrCrossShard, got %v", err)
+	}
+	if err := c.DependsOn("0a", "0z"); err != nil {
+		t.Errorf("Expected a same-shard dependency, got %v", err)
+	}
+}
+
+func TestShardedCacheWithHasher(t *testing.T) {
+	type tenantKey struct {
+		Tenant int
+		ID     string
+	}
+
+	// keep each tenant on one shard
+	c := NewSharded(4,
+		WithCapacity[tenantKey, int](100),
+		WithHasher[tenantKey, int](hasher.Func[tenantKey](func(k tenantKey) uint64 {
+			return uint64(k.Tenant)
+		})),
+	)
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		c.Set(tenantKey{Tenant: 2, ID: fmt.Sprint(i)}, i)
+	}
+
+	for i, stats := range c.ShardStats() {
+		want := 0
+		if i == 2 {
+			want = 10
+		}
+		if stats.Size != want {
+			t.Errorf("Expected shard %d to hold %d keys, got %d", i, want, stats.Size)
+		}
+	}
+	if v, ok := c.Get(tenantKey{Tenant: 2, ID: "3"}); !ok || v != 3 {
+		t.Errorf("Expected 3, got %v", v)
+	}
+}
+
+func TestCacheReadBuffer(t *testing.T) {
+	c := New(
+		WithCapacity[string, int](3),
+		WithReadBuffer[string, int](true),
+	)
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a")
+
+	// the hit is buffered, so the next write replays it before evicting
+	c.Set("d", 4)
+	if !c.Contains("a") || c.Contains("b") {
+		t.Errorf("Expected b evicted and a kept, got keys %v", c.Keys())
+	}
+
+	// buffered hits for removed keys don't come back into the policy
+	c.Get("c")
+	c.Delete("c")
+	c.Set("e", 5)
+	if n := c.(*cache[string, int]).policies[PriorityNormal].Size(); n != c.Size() {
+		t.Errorf("Expected policy to track %d keys, got %d", c.Size(), n)
+	}
+}
+
+func TestCacheReadBufferConcurrent(t *testing.T) {
+	c := New(
+		WithCapacity[int, int](64),
+		WithReadBuffer[int, int](true),
+	)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				if i%10 == 0 {
+					c.Set((g*7+i)%128, i)
+				} else {
+					c.Get(i % 128)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := c.(*cache[int, int]).policies[PriorityNormal].Size(); n != c.Size() {
+		t.Errorf("Expected policy to track %d keys, got %d", c.Size(), n)
+	}
+}
+
+func TestCacheWithArenaStorage(t *testing.T) {
+	c := New(
+		WithCapacity[string, []byte](2),
+		WithStorage[string, []byte](storage.NewArenaStorage[string, []byte](0, storage.BytesCodec{})),
+	)
+	defer c.Close()
+
+	c.Set("a", []byte("1"))
+	c.Set("b", []byte("2"))
+	c.Get("a")
+	c.Set("c", []byte("3"))
+
+	if v, ok := c.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("Expected a to survive, got %s", v)
+	}
+	if c.Contains("b") || c.Stats().Evictions != 1 {
+		t.Error("Expected b evicted")
+	}
+}
+
+func TestTieredCache(t *testing.T) {
+	l2 := storage.NewMemoryStorage[string, int]()
+	var evicted []string
+	c := NewTiered(l2,
+		WithCapacity[string, int](2),
+		WithOnRemoval(func(key string, value int, reason RemovalReason) {
+			if reason == ReasonEvicted {
+				evicted = append(evicted, key)
+			}
+		}),
+	)
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	// a was demoted, not dropped, so nothing was reported
+	if len(evicted) != 0 || l2.Size() != 1 {
+		t.Fatalf("Expected a demoted to L2 without an eviction, evicted %v", evicted)
+	}
+	if c.Size() != 3 || !c.Contains("a") {
+		t.Errorf("Expected 3 items across tiers, got %d", c.Size())
+	}
+
+	// L2 hit promotes a, which demotes b
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a=1 from L2, got %v", v)
+	}
+	if _, ok := l2.Peek("a"); ok {
+		t.Error("Expected promoted key to leave L2")
+	}
+	if _, ok := l2.Peek("b"); !ok {
+		t.Error("Expected b demoted by the promotion")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a=1 from L1, got %v", v)
+	}
+	c.Get("missing")
+
+	stats := c.Stats()
+	if stats.L1Hits != 1 || stats.L2Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit per tier and 1 miss, got %+v", stats)
+	}
+	if stats.Promotions != 1 || stats.Demotions != 2 || stats.L2Size != 1 {
+		t.Errorf("Expected 1 promotion and 2 demotions, got %+v", stats)
+	}
+
+	// writes replace the L2 copy
+	c.Set("b", 20)
+	if v, _ := c.Get("b"); v != 20 {
+		t.Errorf("Expected b=20, got %v", v)
+	}
+	if _, ok := l2.Peek("b"); ok {
+		t.Error("Expected Set to drop the stale L2 copy")
+	}
+
+	// deletes reach both tiers
+	if !c.Delete("c") || c.Contains("c") {
+		t.Error("Expected c deleted from L2")
+	}
+
+	c.Clear()
+	if c.Size() != 0 {
+		t.Errorf("Expected both tiers empty, got %d", c.Size())
+	}
+}
+
+func TestTieredCacheL2Capacity(t *testing.T) {
+	l2 := storage.NewMemoryStorage[string, int]()
+	var evicted []string
+	c := NewTiered(l2,
+		WithCapacity[string, int](1),
+		WithL2Capacity[string, int](2),
+		WithOnRemoval(func(key string, value int, reason RemovalReason) {
+			if reason == ReasonEvicted {
+				evicted = append(evicted, key)
+			}
+		}),
+	)
+	defer c.Close()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.Set(key, 1)
+	}
+
+	// a and b fit in L2, c had nowhere to go
+	if l2.Size() != 2 || c.Size() != 3 {
+		t.Errorf("Expected L2 capped at 2, got %d in L2 and %d total", l2.Size(), c.Size())
+	}
+	if len(evicted) != 1 || evicted[0] != "c" {
+		t.Errorf("Expected only c reported as evicted, got %v", evicted)
+	}
+	if c.Stats().Demotions != 2 {
+		t.Errorf("Expected 2 demotions, got %+v", c.Stats())
+	}
+}
+
+// L2 that silently drops every write
+type droppingStorage struct {
+	storage.Storage[string, int]
+}
+
+func (droppingStorage) Set(string, *storage.Item[int]) {}
+
+func TestTieredCacheFailedDemotion(t *testing.T) {
+	var evicted []string
+	c := NewTiered[string, int](droppingStorage{storage.NewMemoryStorage[string, int]()},
+		WithCapacity[string, int](1),
+		WithOnRemoval(func(key string, value int, reason RemovalReason) {
+			if reason == ReasonEvicted {
+				evicted = append(evicted, key)
+			}
+		}),
+	)
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("Expected a dropped demotion reported as an eviction, got %v", evicted)
+	}
+	if c.Stats().Demotions != 0 {
+		t.Errorf("Expected no demotions, got %+v", c.Stats())
+	}
+}
+
+func TestTieredCacheL2Sweep(t *testing.T) {
+	l2 := storage.NewMemoryStorage[string, int]()
+	c := NewTiered(l2,
+		WithCapacity[string, int](1),
+		WithL2Capacity[string, int](1),
+	)
+	defer c.Close()
+
+	c.SetWithTTL("short", 1, 10*time.Millisecond)
+	c.Set("a", 2)
+	time.Sleep(20 * time.Millisecond)
+
+	// the expired entry makes room for a
+	c.Set("b", 3)
+	if _, ok := l2.Peek("a"); !ok || l2.Size() != 1 {
+		t.Errorf("Expected a to replace the expired entry in L2, got %d entries", l2.Size())
+	}
+}
+
+func TestTieredCacheTTL(t *testing.T) {
+	l2 := storage.NewMemoryStorage[string, string]()
+	c := NewTiered(l2, WithCapacity[string, string](1))
+	defer c.Close()
+
+	c.SetWithTTL("short", "x", 30*time.Millisecond)
+	c.Set("other", "y")
+
+	// expiry follows the item into L2
+	item, ok := l2.Peek("short")
+	if !ok || !item.HasTTL {
+		t.Fatalf("Expected demoted item to keep its TTL, got %+v", item)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Error("Expected expired item to miss in L2")
+	}
+	if c.Stats().Misses != 1 {
+		t.Errorf("Expected one miss, got %+v", c.Stats())
+	}
+}
+
+func TestTieredCacheWithDiskStorage(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *TieredCache[string, string] {
+		disk, err := storage.OpenDiskStorage[string, string](dir, codec.JSON[string]{}, storage.DiskOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewTiered[string, string](disk, WithCapacity[string, string](2))
+	}
+
+	c := open()
+	for i := range 10 {
+		c.Set(fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i))
+	}
+	if stats := c.Stats(); stats.Demotions != 8 || stats.L2Size != 8 {
+		t.Fatalf("Expected 8 demotions to disk, got %+v", stats)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// demoted entries outlive the process, L1 contents don't
+	c = open()
+	defer c.Close()
+	for i := range 8 {
+		key := fmt.Sprintf("key%d", i)
+		if v, ok := c.Get(key); !ok || v != fmt.Sprintf("value%d", i) {
+			t.Errorf("Expected %s from disk, got %q", key, v)
+		}
+	}
+	if _, ok := c.Get("key9"); ok {
+		t.Error("Expected L1-only entry to be gone")
+	}
+}
+
+func TestTieredCacheConcurrent(t *testing.T) {
+	c := NewTiered(storage.NewMemoryStorage[int, int](), WithCapacity[int, int](16))
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				key := (w*1000 + i) % 64
+				c.Set(key, key)
+				if v, ok := c.Get(key); ok && v != key {
+					t.Errorf("Expected %d, got %d", key, v)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// every key lives in exactly one tier
+	seen := make(map[int]bool)
+	for _, key := range c.Keys() {
+		if seen[key] {
+			t.Errorf("Expected key %d in one tier only", key)
+		}
+		seen[key] = true
+	}
+	if len(seen) != 64 {
+		t.Errorf("Expected 64 keys across tiers, got %d", len(seen))
+	}
+}
+
+func orderedKeys[K comparable, V any](c Cache[K, V]) []K {
+	var keys []K
+	for key := range c.AllOrdered() {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	src := New(WithCapacity[string, int](10))
+	defer src.Close()
+
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		src.Set(key, i)
+	}
+	src.Get("a")
+	src.SetWithTTL("ttl", 5, time.Hour)
+	src.SetPinned("pinned", 6, 0)
+	src.SetWithPriority("low", 7, 0, PriorityLow)
+	src.SetWithTTL("expired", 8, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := New(WithCapacity[string, int](10))
+	defer dst.Close()
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// eviction order comes back with the entries
+	if want, got := orderedKeys(src), orderedKeys(dst); !slices.Equal(want, got) {
+		t.Errorf("Expected eviction order %v, got %v", want, got)
+	}
+
+	if dst.Size() != 8 || dst.Contains("expired") {
+		t.Errorf("Expected 8 live entries restored, got %v", dst.Keys())
+	}
+	for key, value := range src.All() {
+		if got, ok := dst.Peek(key); !ok || got != value {
+			t.Errorf("Expected %s=%d, got %d", key, value, got)
+		}
+	}
+	if _, expires, _ := dst.GetWithExpiry("ttl"); time.Until(expires) < 59*time.Minute {
+		t.Errorf("Expected remaining TTL to carry over, expires %v", expires)
+	}
+	if _, expires, _ := dst.GetWithExpiry("a"); !expires.IsZero() {
+		t.Error("Expected entry without TTL to stay without one")
+	}
+	if dst.Stats().Pinned != 1 {
+		t.Error("Expected pinned entry to stay pinned")
+	}
+}
+
+func TestSnapshotRestoreKeepsHottest(t *testing.T) {
+	src := New(WithCapacity[int, int](100))
+	defer src.Close()
+	for i := range 100 {
+		src.Set(i, i)
+	}
+	for i := range 10 {
+		src.Get(i)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := New(WithCapacity[int, int](20))
+	defer dst.Close()
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// the 10 recently read and the 10 last written survive
+	for i := range 10 {
+		if !dst.Contains(i) || !dst.Contains(90+i) {
+			t.Errorf("Expected %d and %d to survive the smaller restore", i, 90+i)
+		}
+	}
+}
+
+func TestSnapshotCodecAndTTLGap(t *testing.T) {
+	opts := []Option[string, []string]{
+		WithSnapshotCodec[string, []string](codec.JSON[string]{}, codec.JSON[[]string]{}),
+	}
+	src := New(opts...)
+	defer src.Close()
+	src.Set("list", []string{"x", "y"})
+	src.SetWithTTL("short", []string{"z"}, 30*time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`["x","y"]`)) {
+		t.Error("Expected values encoded with the JSON codec")
+	}
+
+	// time spent on disk counts against the TTL
+	time.Sleep(50 * time.Millisecond)
+	dst := New(opts...)
+	defer dst.Close()
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := dst.Get("list"); !ok || !slices.Equal(v, []string{"x", "y"}) {
+		t.Errorf("Expected list restored, got %v", v)
+	}
+	if dst.Contains("short") {
+		t.Error("Expected entry that expired since the snapshot to be skipped")
+	}
+}
+
+func TestSnapshotRestoreErrors(t *testing.T) {
+	src := New[string, int]()
+	defer src.Close()
+	src.Set("a", 1)
+	src.Set("b", 2)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	restore := func(data []byte) error {
+		dst := New[string, int]()
+		defer dst.Close()
+		return dst.Restore(bytes.NewReader(data))
+	}
+
+	if err := restore([]byte("not a snapshot")); !errors.Is(err, ErrSnapshotFormat) {
+		t.Errorf("Expected ErrSnapshotFormat, got %v", err)
+	}
+
+	future := slices.Clone(data)
+	future[4] = 99
+	if err := restore(future); !errors.Is(err, ErrSnapshotVersion) {
+		t.Errorf("Expected ErrSnapshotVersion, got %v", err)
+	}
+
+	if err := restore(data[:len(data)-5]); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("Expected truncated snapshot to be rejected, got %v", err)
+	}
+
+	flipped := slices.Clone(data)
+	flipped[len(flipped)-2] ^= 1
+	if err := restore(flipped); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("Expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestSnapshotShardedAndNamespace(t *testing.T) {
+	parent := New(WithCapacity[string, int](100))
+	defer parent.Close()
+	users := Namespace(parent, "users:")
+	defer users.Close()
+	users.Set("alice", 1)
+	users.Set("bob", 2)
+	parent.Set("other", 3)
+
+	var buf bytes.Buffer
+	if err := users.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// namespace snapshots hold local keys, restorable anywhere
+	sharded := NewSharded[string, int](4, WithCapacity[string, int](100))
+	defer sharded.Close()
+	if err := sharded.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if sharded.Size() != 2 || !sharded.Contains("alice") || sharded.Contains("other") {
+		t.Errorf("Expected only the namespace's entries, got %v", sharded.Keys())
+	}
+
+	other := Namespace(parent, "archive:")
+	defer other.Close()
+	if err := other.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := parent.Get("archive:bob"); !ok || v != 2 {
+		t.Errorf("Expected entries restored under the new prefix, got %v", parent.Keys())
+	}
+
+	// sharded dumps round-trip through an unsharded cache
+	buf.Reset()
+	if err := sharded.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	plain := New[string, int]()
+	defer plain.Close()
+	if err := plain.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := plain.Get("bob"); !ok || v != 2 || plain.Size() != 2 {
+		t.Errorf("Expected sharded snapshot restored, got %v", plain.Keys())
+	}
+}
+
+func TestSnapshotConcurrentWrites(t *testing.T) {
+	c := New(WithCapacity[int, int](1000))
+	defer c.Close()
+	for i := range 1000 {
+		c.Set(i, i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 5000 {
+			c.Set(i%2000, i)
+		}
+	}()
+
+	var buf bytes.Buffer
+	if err := c.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	dst := New(WithCapacity[int, int](1000))
+	defer dst.Close()
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Size() == 0 {
+		t.Error("Expected entries in a snapshot taken during writes")
+	}
+}
+
+func TestConditionalOperationsKeepTTL(t *testing.T) {
+	c := New(WithCapacity[string, string](10))
+	defer c.Close()
+
+	ops := map[string]func(key string){
+		"Replace": func(key string) { c.Replace(key, "new") },
+		"CompareAndSwap": func(key string) {
+			c.CompareAndSwap(key, "old", "new", nil)
+		},
+		"Compute": func(key string) {
+			c.Compute(key, func(old string, exists bool) (string, ComputeAction) {
+				return "new", ComputeSet
+			})
+		},
+	}
+
+	for name, op := range ops {
+		c.SetWithTTL(name, "old", 50*time.Millisecond)
+		_, before, _ := c.GetWithExpiry(name)
+		op(name)
+
+		value, after, ok := c.GetWithExpiry(name)
+		if !ok || value != "new" {
+			t.Errorf("%s: expected new value, got %q", name, value)
+		}
+		if !after.Equal(before) {
+			t.Errorf("%s: expected expiry %v kept, got %v", name, before, after)
+		}
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	for name := range ops {
+		if c.Contains(name) {
+			t.Errorf("%s: expected key to expire on its original TTL", name)
+		}
+	}
+}
+
+func TestGetOrSetWriteFailure(t *testing.T) {
+	w := newRecordingWriter()
+	c := New(WithWriteThrough[string, string](w))
+	defer c.Close()
+
+	w.fail = true
+	actual, loaded, err := c.GetOrSet("key", "value")
+	if err == nil || loaded || actual != "" {
+		t.Errorf("Expected failed store to be reported, got %q %v %v", actual, loaded, err)
+	}
+	if c.Contains("key") {
+		t.Error("Expected key not cached after failed write")
+	}
+
+	w.fail = false
+	if actual, loaded, err := c.GetOrSet("key", "value"); err != nil || loaded || actual != "value" {
+		t.Errorf("Expected value stored, got %q %v %v", actual, loaded, err)
+	}
+}
+
 func BenchmarkCacheSet(b *testing.B) {
 	c := New(WithCapacity[string, string](1000))
 
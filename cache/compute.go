@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"caching-lib/storage"
+	"reflect"
+	"sync/atomic"
+)
+
+// ComputeAction - what Compute does with the value returned by its func
+type ComputeAction int
+
+const (
+	// ComputeSet - store the returned value
+	ComputeSet ComputeAction = iota
+	// ComputeDelete - remove the key
+	ComputeDelete
+	// ComputeNoop - leave the entry untouched
+	ComputeNoop
+)
+
+// GetOrSet - returns the cached value, or stores value if absent.
+// loaded reports whether the value came from the cache, err is the
+// backing store's error when storing failed.
+func (c *cache[K, V]) GetOrSet(key K, value V) (actual V, loaded bool, err error) {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if item, exists := c.lookupLocked(key); exists {
+		c.access(key)
+		atomic.AddInt64(&c.hits, 1)
+		return item.Value, true, nil
+	}
+
+	atomic.AddInt64(&c.misses, 1)
+	if err := c.trySetLocked(key, value, c.defaultTTL, PriorityNormal); err != nil {
+		var zero V
+		return zero, false, err
+	}
+	return value, false, nil
+}
+
+// SetIfAbsent - stores value only if key is not cached
+func (c *cache[K, V]) SetIfAbsent(key K, value V) bool {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if _, exists := c.lookupLocked(key); exists {
+		return false
+	}
+	return c.setLocked(key, value, c.defaultTTL, PriorityNormal)
+}
+
+// Replace - stores value only if key is already cached, keeping its expiry
+func (c *cache[K, V]) Replace(key K, value V) bool {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	item, exists := c.lookupLocked(key)
+	if !exists {
+		return false
+	}
+	return c.updateLocked(key, value, item)
+}
+
+// CompareAndSwap - stores value if the cached value equals old, keeping the
+// expiry. A nil equal falls back to reflect.DeepEqual.
+func (c *cache[K, V]) CompareAndSwap(key K, old, value V, equal func(a, b V) bool) bool {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	item, exists := c.lookupLocked(key)
+	if !exists || !valuesEqual(equal, item.Value, old) {
+		return false
+	}
+	return c.updateLocked(key, value, item)
+}
+
+// CompareAndDelete - removes key if the cached value equals old.
+// A nil equal falls back to reflect.DeepEqual.
+func (c *cache[K, V]) CompareAndDelete(key K, old V, equal func(a, b V) bool) bool {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	item, exists := c.lookupLocked(key)
+	if !exists || !valuesEqual(equal, item.Value, old) {
+		return false
+	}
+	if err := c.write(WriteOp[K, V]{Key: key, Delete: true}); err != nil {
+		return false
+	}
+	c.unlinkLocked(key)
+	return c.removeLocked(key, ReasonDeleted)
+}
+
+// Compute - atomically derives a new value from the current one. fn runs
+// under the cache lock and must not call back into the cache. Existing keys
+// keep their expiry, new ones get the default TTL. Returns the resulting
+// value and whether key is cached afterwards.
+func (c *cache[K, V]) Compute(key K, fn func(old V, exists bool) (V, ComputeAction)) (V, bool) {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	var old V
+	item, exists := c.lookupLocked(key)
+	if exists {
+		old = item.Value
+	}
+
+	value, action := fn(old, exists)
+	switch action {
+	case ComputeSet:
+		var stored bool
+		if exists {
+			stored = c.updateLocked(key, value, item)
+		} else {
+			stored = c.setLocked(key, value, c.defaultTTL, PriorityNormal)
+		}
+		if !stored {
+			return old, exists
+		}
+		return value, true
+	case ComputeDelete:
+		if exists {
+			if err := c.write(WriteOp[K, V]{Key: key, Delete: true}); err != nil {
+				return old, true
+			}
+			c.unlinkLocked(key)
+			c.removeLocked(key, ReasonDeleted)
+		}
+		var zero V
+		return zero, false
+	default:
+		return old, exists
+	}
+}
+
+// live item for key, dropping it if expired (assumes lock held)
+func (c *cache[K, V]) lookupLocked(key K) (*storage.Item[V], bool) {
+	item, exists := c.storage.Peek(key)
+	if !exists {
+		return nil, false
+	}
+	if item.IsExpired() {
+		c.removeLocked(key, ReasonExpired)
+		return nil, false
+	}
+	return item, true
+}
+
+func valuesEqual[V any](equal func(a, b V) bool, a, b V) bool {
+	if equal == nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return equal(a, b)
+}
@@ -0,0 +1,82 @@
+package cache
+
+import "time"
+
+// Integer - value types usable by CounterCache
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// CounterCache - integer-valued cache with atomic Increment/Decrement
+type CounterCache[K comparable, N Integer] struct {
+	Cache[K, N]
+	c *cache[K, N]
+}
+
+// NewCounterCache - creates a counter cache, takes the same options as New
+func NewCounterCache[K comparable, N Integer](opts ...Option[K, N]) *CounterCache[K, N] {
+	c := New(opts...).(*cache[K, N])
+	return &CounterCache[K, N]{Cache: c, c: c}
+}
+
+// Increment - adds delta, absent keys start at delta with the default TTL
+func (cc *CounterCache[K, N]) Increment(key K, delta N) N {
+	return cc.add(key, delta, false, cc.c.defaultTTL, false)
+}
+
+// Decrement - subtracts delta, absent keys start at -delta with the default
+// TTL. Unsigned counters stop at 0 instead of wrapping around.
+func (cc *CounterCache[K, N]) Decrement(key K, delta N) N {
+	return cc.add(key, delta, true, cc.c.defaultTTL, false)
+}
+
+// IncrementWithTTL - like Increment, ttl applies when the key is created,
+// or on every call if reset is set
+func (cc *CounterCache[K, N]) IncrementWithTTL(key K, delta N, ttl time.Duration, reset bool) N {
+	return cc.add(key, delta, false, ttl, reset)
+}
+
+// old plus or minus delta, unsigned results clamped at 0
+func step[N Integer](old, delta N, decrement bool) N {
+	if !decrement {
+		return old + delta
+	}
+	var zero N
+	if unsigned := zero-1 > zero; unsigned && old < delta {
+		return 0
+	}
+	return old - delta
+}
+
+// adds or subtracts delta keeping the current expiry unless reset. If a
+// write-through store rejects the change the unchanged value is returned.
+func (cc *CounterCache[K, N]) add(key K, delta N, decrement bool, ttl time.Duration, reset bool) N {
+	c := cc.c
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	item, exists := c.lookupLocked(key)
+	if !exists {
+		value := step(0, delta, decrement)
+		if !c.setLocked(key, value, ttl, PriorityNormal) {
+			return 0
+		}
+		return value
+	}
+
+	old := item.Value
+	value := step(old, delta, decrement)
+	var ok bool
+	if reset {
+		ok = c.setLocked(key, value, ttl, c.priorityOf(key))
+	} else {
+		ok = c.updateLocked(key, value, item)
+	}
+	if !ok {
+		return old
+	}
+	return value
+}
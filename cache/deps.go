@@ -0,0 +1,121 @@
+package cache
+
+import "errors"
+
+var (
+	// ErrDependencyCycle - declaring the dependency would make a key depend on itself
+	ErrDependencyCycle = errors.New("cache: dependency cycle")
+	// ErrKeyNotCached - dependencies can only be declared for cached keys
+	ErrKeyNotCached = errors.New("cache: key not cached")
+)
+
+// DependsOn - invalidates key whenever any of deps is written, deleted or
+// expires. Cascades follow chains of dependents up to the configured depth.
+// Edges outlive invalidation, eviction and expiry of key, so its next value
+// depends on deps too, until Delete or RemoveDependency.
+func (c *cache[K, V]) DependsOn(key K, deps ...K) error {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if _, exists := c.lookupLocked(key); !exists {
+		return ErrKeyNotCached
+	}
+
+	for _, dep := range deps {
+		if dep == key || c.dependsLocked(dep, key) {
+			return ErrDependencyCycle
+		}
+	}
+
+	for _, dep := range deps {
+		if c.dependencies[key] == nil {
+			c.dependencies[key] = make(map[K]struct{})
+		}
+		c.dependencies[key][dep] = struct{}{}
+
+		if c.dependents[dep] == nil {
+			c.dependents[dep] = make(map[K]struct{})
+		}
+		c.dependents[dep][key] = struct{}{}
+	}
+	return nil
+}
+
+// RemoveDependency - drops key's dependencies on deps, or all of them when
+// no deps are given
+func (c *cache[K, V]) RemoveDependency(key K, deps ...K) {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if len(deps) == 0 {
+		c.unlinkLocked(key)
+		return
+	}
+	for _, dep := range deps {
+		delete(c.dependencies[key], dep)
+		if dependents := c.dependents[dep]; dependents != nil {
+			delete(dependents, key)
+			if len(dependents) == 0 {
+				delete(c.dependents, dep)
+			}
+		}
+	}
+	if len(c.dependencies[key]) == 0 {
+		delete(c.dependencies, key)
+	}
+}
+
+// reports whether key transitively depends on target (assumes lock held)
+func (c *cache[K, V]) dependsLocked(key, target K) bool {
+	visited := make(map[K]struct{})
+	stack := []K{key}
+	for len(stack) > 0 {
+		k := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for dep := range c.dependencies[k] {
+			if dep == target {
+				return true
+			}
+			if _, seen := visited[dep]; !seen {
+				visited[dep] = struct{}{}
+				stack = append(stack, dep)
+			}
+		}
+	}
+	return false
+}
+
+// invalidates everything depending on key, their edges stay (assumes lock held)
+func (c *cache[K, V]) cascadeLocked(key K) {
+	dependents := c.dependents[key]
+	if len(dependents) == 0 || c.cascadeDepth >= c.maxCascadeDepth {
+		return
+	}
+
+	keys := make([]K, 0, len(dependents))
+	for dep := range dependents {
+		keys = append(keys, dep)
+	}
+
+	c.cascadeDepth++
+	for _, dep := range keys {
+		c.invalidateLocked(dep)
+	}
+	c.cascadeDepth--
+}
+
+// drops key's own dependency edges (assumes lock held)
+func (c *cache[K, V]) unlinkLocked(key K) {
+	for dep := range c.dependencies[key] {
+		dependents := c.dependents[dep]
+		delete(dependents, key)
+		if len(dependents) == 0 {
+			delete(c.dependents, dep)
+		}
+	}
+	delete(c.dependencies, key)
+}
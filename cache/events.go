@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"iter"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType - kind of cache mutation
+type EventType int
+
+const (
+	// EventSet - new key stored
+	EventSet EventType = iota
+	// EventUpdate - existing key overwritten
+	EventUpdate
+	// EventDelete - key removed by Delete/DeleteBatch
+	EventDelete
+	// EventEvict - key dropped by the eviction policy
+	EventEvict
+	// EventExpire - key dropped after its TTL ran out
+	EventExpire
+	// EventClear - key removed by Clear
+	EventClear
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventUpdate:
+		return "update"
+	case EventDelete:
+		return "delete"
+	case EventEvict:
+		return "evict"
+	case EventExpire:
+		return "expire"
+	case EventClear:
+		return "clear"
+	default:
+		return "unknown"
+	}
+}
+
+// Event - single cache mutation. Value is the new value for set/update
+// and the removed value otherwise.
+type Event[K comparable, V any] struct {
+	Type  EventType
+	Key   K
+	Value V
+	Time  time.Time
+}
+
+// EventFilter - selects events for a subscription, nil means all
+type EventFilter[K comparable, V any] func(Event[K, V]) bool
+
+// Delivery - what a publisher does when a subscriber's buffer is full
+type Delivery int
+
+const (
+	// DropOnFull - skip the event and count it in Dropped
+	DropOnFull Delivery = iota
+	// BlockOnFull - wait for the subscriber, stalling cache writes
+	BlockOnFull
+)
+
+// Subscription - stream of cache events. Events are published under the
+// cache lock, so they arrive in mutation order.
+type Subscription[K comparable, V any] struct {
+	ch       chan Event[K, V]
+	filter   EventFilter[K, V]
+	delivery Delivery
+	dropped  int64
+
+	hub       *eventHub[K, V]
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// C - channel of events, closed when the subscription or cache is closed
+func (s *Subscription[K, V]) C() <-chan Event[K, V] {
+	return s.ch
+}
+
+// Events - iterates events until the subscription or cache is closed
+func (s *Subscription[K, V]) Events() iter.Seq[Event[K, V]] {
+	return func(yield func(Event[K, V]) bool) {
+		for ev := range s.ch {
+			if !yield(ev) {
+				return
+			}
+		}
+	}
+}
+
+// Dropped - events skipped because the buffer was full
+func (s *Subscription[K, V]) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close - stops delivery and closes the channel
+func (s *Subscription[K, V]) Close() {
+	s.closeOnce.Do(func() {
+		// unblock a publisher waiting on us before taking the hub lock
+		close(s.done)
+		s.hub.remove(s)
+		close(s.ch)
+	})
+}
+
+// sends ev unless filtered out (assumes hub read lock held)
+func (s *Subscription[K, V]) deliver(ev Event[K, V]) {
+	if s.filter != nil && !s.filter(ev) {
+		return
+	}
+
+	if s.delivery == BlockOnFull {
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+		}
+		return
+	}
+
+	select {
+	case s.ch <- ev:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+// fans events out to subscribers
+type eventHub[K comparable, V any] struct {
+	mu   sync.RWMutex
+	subs []*Subscription[K, V]
+	n    int32
+}
+
+func (h *eventHub[K, V]) subscribe(buffer int, filter EventFilter[K, V], delivery Delivery) *Subscription[K, V] {
+	if buffer < 0 {
+		buffer = 0
+	}
+
+	s := &Subscription[K, V]{
+		ch:       make(chan Event[K, V], buffer),
+		filter:   filter,
+		delivery: delivery,
+		hub:      h,
+		done:     make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	h.subs = append(h.subs, s)
+	atomic.StoreInt32(&h.n, int32(len(h.subs)))
+	h.mu.Unlock()
+	return s
+}
+
+func (h *eventHub[K, V]) remove(s *Subscription[K, V]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, sub := range h.subs {
+		if sub == s {
+			h.subs = append(h.subs[:i], h.subs[i+1:]...)
+			break
+		}
+	}
+	atomic.StoreInt32(&h.n, int32(len(h.subs)))
+}
+
+// skips all work when nobody is listening
+func (h *eventHub[K, V]) active() bool {
+	return atomic.LoadInt32(&h.n) > 0
+}
+
+func (h *eventHub[K, V]) publish(typ EventType, key K, value V) {
+	if !h.active() {
+		return
+	}
+	h.publishEvent(Event[K, V]{Type: typ, Key: key, Value: value, Time: time.Now()})
+}
+
+func (h *eventHub[K, V]) publishEvent(ev Event[K, V]) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, s := range h.subs {
+		s.deliver(ev)
+	}
+}
+
+// closes every subscription
+func (h *eventHub[K, V]) close() {
+	h.mu.RLock()
+	subs := append([]*Subscription[K, V](nil), h.subs...)
+	h.mu.RUnlock()
+
+	for _, s := range subs {
+		s.Close()
+	}
+}
+
+// maps a removal reason to its event type
+func removalEventType(reason RemovalReason) EventType {
+	switch reason {
+	case ReasonEvicted:
+		return EventEvict
+	case ReasonExpired:
+		return EventExpire
+	case ReasonCleared:
+		return EventClear
+	case ReasonReplaced:
+		return EventUpdate
+	default:
+		return EventDelete
+	}
+}
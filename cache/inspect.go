@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"caching-lib/storage"
+	"sync/atomic"
+	"time"
+)
+
+// Peek - reads a value without touching policy order or stats
+func (c *cache[K, V]) Peek(key K) (V, bool) {
+	if c.threadSafe {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+
+	var zero V
+	item, exists := c.storage.Peek(key)
+	if !exists || item.IsExpired() {
+		return zero, false
+	}
+	return item.Value, true
+}
+
+// GetWithExpiry - like Get, also returns the expiry time (zero if no TTL)
+func (c *cache[K, V]) GetWithExpiry(key K) (V, time.Time, bool) {
+	if c.threadSafe {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+
+	var zero V
+	item, exists := c.storage.Peek(key)
+	if exists && !item.IsExpired() {
+		c.accessRead(key)
+		atomic.AddInt64(&c.hits, 1)
+		if !item.HasTTL {
+			return item.Value, time.Time{}, true
+		}
+		return item.Value, item.ExpiresAt, true
+	}
+
+	if exists {
+		c.expireFromRead(key)
+	}
+	atomic.AddInt64(&c.misses, 1)
+	return zero, time.Time{}, false
+}
+
+// Touch - bumps recency without reading the value or counting stats
+func (c *cache[K, V]) Touch(key K) bool {
+	if c.threadSafe {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+
+	item, exists := c.storage.Peek(key)
+	if !exists || item.IsExpired() {
+		return false
+	}
+	c.accessRead(key)
+	return true
+}
+
+// Expire - sets a new TTL on an existing key, ttl <= 0 expires it now
+func (c *cache[K, V]) Expire(key K, ttl time.Duration) bool {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	item, exists := c.lookupLocked(key)
+	if !exists {
+		return false
+	}
+
+	if ttl <= 0 {
+		return c.removeLocked(key, ReasonExpired)
+	}
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	c.retimeLocked(key, item, ttl)
+	return true
+}
+
+// Persist - removes the TTL from an existing key
+func (c *cache[K, V]) Persist(key K) bool {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	item, exists := c.lookupLocked(key)
+	if !exists {
+		return false
+	}
+	c.retimeLocked(key, item, 0)
+	return true
+}
+
+// swaps in a copy of existing with a new TTL (assumes lock held)
+func (c *cache[K, V]) retimeLocked(key K, existing *storage.Item[V], ttl time.Duration) {
+	item := c.itemPool.Get()
+	item.Value = existing.Value
+	item.SetTTL(ttl)
+	item.Version = c.nextVersion()
+	c.storage.Set(key, item)
+}
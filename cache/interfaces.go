@@ -1,8 +1,12 @@
 package cache
 
 import (
+	"caching-lib/codec"
 	"caching-lib/eviction"
+	"caching-lib/hasher"
 	"caching-lib/storage"
+	"io"
+	"iter"
 	"time"
 )
 
@@ -17,10 +21,68 @@ type Cache[K comparable, V any] interface {
 	Keys() []K
 	Stats() Stats
 	Contains(key K) bool
+	// iterators, read-locked for the whole loop
+	All() iter.Seq2[K, V]
+	KeysSeq() iter.Seq[K]
+	ValuesSeq() iter.Seq[V]
+	AllOrdered() iter.Seq2[K, V]
 	// batch ops
 	SetBatch(items map[K]V) int
 	GetBatch(keys []K) map[K]V
 	DeleteBatch(keys []K) int
+	// priority classes, lowest evicted first
+	SetWithPriority(key K, value V, ttl time.Duration, priority Priority) bool
+	// reads and TTL changes that leave the value alone
+	Peek(key K) (V, bool)
+	GetWithExpiry(key K) (V, time.Time, bool)
+	Touch(key K) bool
+	Expire(key K, ttl time.Duration) bool
+	Persist(key K) bool
+	// atomic check-then-act
+	GetOrSet(key K, value V) (actual V, loaded bool, err error)
+	SetIfAbsent(key K, value V) bool
+	Replace(key K, value V) bool
+	CompareAndSwap(key K, old, value V, equal func(a, b V) bool) bool
+	CompareAndDelete(key K, old V, equal func(a, b V) bool) bool
+	Compute(key K, fn func(old V, exists bool) (V, ComputeAction)) (V, bool)
+	// tag-based group invalidation
+	SetWithTags(key K, value V, ttl time.Duration, tags ...string) bool
+	InvalidateTag(tag string) int
+	// key dependencies, cascading invalidation
+	DependsOn(key K, deps ...K) error
+	RemoveDependency(key K, deps ...K)
+
+	// optimistic transactions
+	Begin() *Txn[K, V]
+	// warm starts, entries in eviction order with remaining TTLs
+	Snapshot(w io.Writer) error
+	Restore(r io.Reader) error
+	// pinned entries, never evicted
+	SetPinned(key K, value V, ttl time.Duration) bool
+	Unpin(key K) bool
+	// change events
+	Subscribe(buffer int, filter EventFilter[K, V], delivery Delivery) *Subscription[K, V]
+	// stops bg work, drains pending write-behind ops
+	Close()
+}
+
+// OrderedCache - optional Cache extension for key-ordered queries. Caches
+// from New and namespace views implement it; without an ordered storage
+// backend the queries return ErrUnorderedStorage.
+type OrderedCache[K comparable, V any] interface {
+	Cache[K, V]
+	Range(from, to K) (iter.Seq2[K, V], error)
+	ScanPrefix(prefix K) (iter.Seq2[K, V], error)
+	DeletePrefix(prefix K) (int, error)
+}
+
+// ScannableCache - optional Cache extension for incremental scans with
+// Redis-style glob patterns. Caches from New and namespace views implement
+// it; non-string keys return ErrNonStringKeys.
+type ScannableCache[K comparable, V any] interface {
+	Cache[K, V]
+	Scan(cursor uint64, pattern string, count int) (keys []K, next uint64, err error)
+	DeleteMatching(pattern string) (int, error)
 }
 
 // Stats - cache metrics
@@ -29,6 +91,7 @@ type Stats struct {
 	Misses    int64
 	Evictions int64
 	Size      int
+	Pinned    int
 	Capacity  int
 	HitRatio  float64
 }
@@ -37,10 +100,42 @@ type Stats struct {
 type Config[K comparable, V any] struct {
 	Capacity       int
 	EvictionPolicy eviction.Policy[K]
-	Storage        storage.Storage[K, V]
-	ThreadSafe     bool
-	DefaultTTL     time.Duration
-	MaxTTL         time.Duration
+	// policies for non-normal priority classes, LRU by default
+	PriorityPolicies map[Priority]eviction.Policy[K]
+	Storage          storage.Storage[K, V]
+	ThreadSafe       bool
+	DefaultTTL       time.Duration
+	MaxTTL           time.Duration
+
+	// backing store
+	Writer               Writer[K, V]
+	WriteMode            WriteMode
+	WriteBehindInterval  time.Duration
+	WriteBehindBatchSize int
+	WriteErrorHandler    func(error)
+
+	// dependency cascades
+	MaxCascadeDepth int
+
+	// removal listeners
+	RemovalListeners []RemovalListener[K, V]
+	AsyncRemoval     bool
+
+	// builds each shard's policy for NewSharded, LRU by default
+	ShardPolicy func(capacity int) eviction.Policy[K]
+
+	// key hashing for shards and Scan cursors, hasher.Default if nil
+	Hasher hasher.Hasher[K]
+
+	// buffer read hits instead of updating the policy on every Get
+	ReadBuffer bool
+
+	// encode Snapshot entries, gob if nil
+	KeyCodec   codec.Codec[K]
+	ValueCodec codec.Codec[V]
+
+	// items NewTiered lets L2 hold, 0 for no limit
+	L2Capacity int
 }
 
 type Option[K comparable, V any] func(*Config[K, V])
@@ -60,6 +155,20 @@ func WithEvictionPolicy[K comparable, V any](policy eviction.Policy[K]) Option[K
 	}
 }
 
+// WithPriorityPolicy - eviction policy for a priority class, EvictionPolicy backs PriorityNormal
+func WithPriorityPolicy[K comparable, V any](priority Priority, policy eviction.Policy[K]) Option[K, V] {
+	return func(c *Config[K, V]) {
+		if priority == PriorityNormal {
+			c.EvictionPolicy = policy
+			return
+		}
+		if c.PriorityPolicies == nil {
+			c.PriorityPolicies = make(map[Priority]eviction.Policy[K])
+		}
+		c.PriorityPolicies[priority] = policy
+	}
+}
+
 func WithStorage[K comparable, V any](storage storage.Storage[K, V]) Option[K, V] {
 	return func(c *Config[K, V]) {
 		c.Storage = storage
@@ -83,3 +192,92 @@ func WithMaxTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
 		c.MaxTTL = ttl
 	}
 }
+
+// WithWriteThrough - writes to w inside Set/Delete, failing the op on error
+func WithWriteThrough[K comparable, V any](w Writer[K, V]) Option[K, V] {
+	return func(c *Config[K, V]) {
+		c.Writer = w
+		c.WriteMode = WriteThrough
+	}
+}
+
+// WithWriteBehind - queues writes to w, flushing every interval or once batchSize ops are pending
+func WithWriteBehind[K comparable, V any](w Writer[K, V], interval time.Duration, batchSize int) Option[K, V] {
+	return func(c *Config[K, V]) {
+		c.Writer = w
+		c.WriteMode = WriteBehind
+		c.WriteBehindInterval = interval
+		c.WriteBehindBatchSize = batchSize
+	}
+}
+
+// WithWriteErrorHandler - receives errors from write-behind flushes, the
+// failed ops are retried on the next flush
+func WithWriteErrorHandler[K comparable, V any](fn func(error)) Option[K, V] {
+	return func(c *Config[K, V]) {
+		c.WriteErrorHandler = fn
+	}
+}
+
+// WithOnRemoval - adds a listener called whenever a key leaves the cache
+func WithOnRemoval[K comparable, V any](fn RemovalListener[K, V]) Option[K, V] {
+	return func(c *Config[K, V]) {
+		c.RemovalListeners = append(c.RemovalListeners, fn)
+	}
+}
+
+// WithAsyncRemoval - delivers removal notices from a bg goroutine instead of under the cache lock
+func WithAsyncRemoval[K comparable, V any](async bool) Option[K, V] {
+	return func(c *Config[K, V]) {
+		c.AsyncRemoval = async
+	}
+}
+
+// WithMaxCascadeDepth - how many levels of dependents an invalidation reaches
+func WithMaxCascadeDepth[K comparable, V any](depth int) Option[K, V] {
+	return func(c *Config[K, V]) {
+		if depth <= 0 {
+			depth = 16 // default
+		}
+		c.MaxCascadeDepth = depth
+	}
+}
+
+// WithShardPolicy - eviction policy factory for NewSharded, called once per shard
+func WithShardPolicy[K comparable, V any](fn func(capacity int) eviction.Policy[K]) Option[K, V] {
+	return func(c *Config[K, V]) {
+		c.ShardPolicy = fn
+	}
+}
+
+// WithHasher - custom key hasher, e.g. for struct keys
+func WithHasher[K comparable, V any](h hasher.Hasher[K]) Option[K, V] {
+	return func(c *Config[K, V]) {
+		c.Hasher = h
+	}
+}
+
+// WithReadBuffer - records hits in striped lossy buffers that are replayed to
+// the eviction policy on the next write or by a bg goroutine, so Get skips
+// the policy lock. Recency becomes approximate. Needs thread safety.
+func WithReadBuffer[K comparable, V any](enabled bool) Option[K, V] {
+	return func(c *Config[K, V]) {
+		c.ReadBuffer = enabled
+	}
+}
+
+// WithSnapshotCodec - codecs for Snapshot and Restore, nil keeps gob
+func WithSnapshotCodec[K comparable, V any](keys codec.Codec[K], values codec.Codec[V]) Option[K, V] {
+	return func(c *Config[K, V]) {
+		c.KeyCodec = keys
+		c.ValueCodec = values
+	}
+}
+
+// WithL2Capacity - caps NewTiered's L2 at n items; once full, L1 evictions
+// are dropped instead of demoted. 0 means no limit.
+func WithL2Capacity[K comparable, V any](n int) Option[K, V] {
+	return func(c *Config[K, V]) {
+		c.L2Capacity = max(n, 0)
+	}
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"caching-lib/eviction"
+	"iter"
+)
+
+// All - iterates live entries in storage order. The cache is read-locked
+// for the whole loop, so the body must not call back into the cache.
+func (c *cache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if c.threadSafe {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+		}
+
+		for key, item := range c.storage.All() {
+			if item.IsExpired() {
+				continue
+			}
+			if !yield(key, item.Value) {
+				return
+			}
+		}
+	}
+}
+
+// KeysSeq - iterates live keys, same locking as All
+func (c *cache[K, V]) KeysSeq() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for key := range c.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// ValuesSeq - iterates live values, same locking as All
+func (c *cache[K, V]) ValuesSeq() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, value := range c.All() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// AllOrdered - iterates live entries from the one evicted last to the one
+// evicted next: pinned keys, then each priority class from high to low in
+// policy order. Falls back to storage order when a policy doesn't implement
+// eviction.Ordered. Same locking as All.
+func (c *cache[K, V]) AllOrdered() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if c.threadSafe {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+		}
+
+		for key := range c.orderedKeys() {
+			if !c.yieldLive(key, yield) {
+				return
+			}
+		}
+	}
+}
+
+// keys in AllOrdered order, expired ones included (assumes read lock held)
+func (c *cache[K, V]) orderedKeys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		classes := make([]eviction.Ordered[K], 0, numPriorities)
+		for p := PriorityHigh; p >= PriorityLow; p-- {
+			if c.policies[p] == nil {
+				continue
+			}
+			ordered, ok := c.policies[p].(eviction.Ordered[K])
+			if !ok {
+				// unordered fallback, Keys so storage isn't locked while we Peek
+				for _, key := range c.storage.Keys() {
+					if !yield(key) {
+						return
+					}
+				}
+				return
+			}
+			classes = append(classes, ordered)
+		}
+
+		for key := range c.pinned {
+			if !yield(key) {
+				return
+			}
+		}
+		for _, ordered := range classes {
+			for key := range ordered.Keys() {
+				if !yield(key) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// yields key if cached and not expired, reports whether to continue
+func (c *cache[K, V]) yieldLive(key K, yield func(K, V) bool) bool {
+	item, exists := c.storage.Peek(key)
+	if !exists || item.IsExpired() {
+		return true
+	}
+	return yield(key, item.Value)
+}
@@ -0,0 +1,133 @@
+package cache
+
+import "sync"
+
+// RemovalReason - why a key left the cache
+type RemovalReason int
+
+const (
+	// ReasonEvicted - dropped by the eviction policy to make room
+	ReasonEvicted RemovalReason = iota
+	// ReasonExpired - TTL ran out
+	ReasonExpired
+	// ReasonDeleted - removed by Delete/DeleteBatch
+	ReasonDeleted
+	// ReasonReplaced - value overwritten by a later Set
+	ReasonReplaced
+	// ReasonCleared - removed by Clear
+	ReasonCleared
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case ReasonEvicted:
+		return "evicted"
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonCleared:
+		return "cleared"
+	default:
+		return "unknown"
+	}
+}
+
+// RemovalListener - called with the old value whenever a key leaves the cache.
+// Sync listeners run under the cache lock and must not call back into the cache.
+type RemovalListener[K comparable, V any] func(key K, value V, reason RemovalReason)
+
+type removalNotice[K comparable, V any] struct {
+	key    K
+	value  V
+	reason RemovalReason
+}
+
+// delivers removal notices inline or from a bg goroutine
+type removalDispatcher[K comparable, V any] struct {
+	listeners []RemovalListener[K, V]
+
+	// async delivery (unbounded so senders never block under the cache lock)
+	mu    sync.Mutex
+	queue []removalNotice[K, V]
+	wake  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// nil when there is nothing to notify
+func newRemovalDispatcher[K comparable, V any](listeners []RemovalListener[K, V], async bool) *removalDispatcher[K, V] {
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	d := &removalDispatcher[K, V]{listeners: listeners}
+	if async {
+		d.wake = make(chan struct{}, 1)
+		d.stop = make(chan struct{})
+		d.done = make(chan struct{})
+		go d.run()
+	}
+	return d
+}
+
+func (d *removalDispatcher[K, V]) notify(key K, value V, reason RemovalReason) {
+	if d.wake == nil {
+		for _, l := range d.listeners {
+			l(key, value, reason)
+		}
+		return
+	}
+
+	d.mu.Lock()
+	d.queue = append(d.queue, removalNotice[K, V]{key: key, value: value, reason: reason})
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *removalDispatcher[K, V]) run() {
+	defer close(d.done)
+
+	for {
+		select {
+		case <-d.wake:
+			d.drain()
+		case <-d.stop:
+			d.drain()
+			return
+		}
+	}
+}
+
+// delivers queued notices in order
+func (d *removalDispatcher[K, V]) drain() {
+	for {
+		d.mu.Lock()
+		batch := d.queue
+		d.queue = nil
+		d.mu.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+		for _, n := range batch {
+			for _, l := range d.listeners {
+				l(n.key, n.value, n.reason)
+			}
+		}
+	}
+}
+
+// stops async delivery after draining the queue
+func (d *removalDispatcher[K, V]) close() {
+	if d.wake != nil {
+		close(d.stop)
+		<-d.done
+	}
+}
@@ -0,0 +1,703 @@
+package cache
+
+import (
+	"io"
+	"iter"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NamespaceView - prefixed view over a shared string-keyed cache. Keys,
+// tags and events are isolated per prefix while capacity and eviction
+// are shared with the parent.
+type NamespaceView[V any] struct {
+	parent Cache[string, V]
+	prefix string
+
+	// per-namespace stats
+	hits   int64
+	misses int64
+
+	// key set and evictions kept by the root cache, nil when the chain of
+	// parents doesn't end in one of ours
+	root       namespaceRoot
+	rootPrefix string
+	release    *namespaceRelease
+
+	// events re-keyed for this namespace, forwarded on first Subscribe
+	events    *eventHub[string, V]
+	forward   *Subscription[string, V]
+	forwardMu sync.Mutex
+}
+
+// NamespaceSeparator - ends every namespace prefix. Separators and
+// backslashes inside names are escaped, so no prefix is the start of another.
+const NamespaceSeparator = ":"
+
+// cache-only removal, used to clear a namespace without touching the backing store
+type invalidator[K comparable] interface {
+	invalidate(keys []K) int
+}
+
+// caches that track the keys of registered namespace prefixes
+type namespaceRoot interface {
+	acquireNamespace(prefix string)
+	releaseNamespace(prefix string)
+	namespaceStats(prefix string) (size int, evictions int64)
+	namespaceKeys(prefix string) []string
+	resetNamespace(prefix string)
+}
+
+// drops a view's registration once, from Close or when the view is collected
+type namespaceRelease struct {
+	once   sync.Once
+	root   namespaceRoot
+	prefix string
+}
+
+func (r *namespaceRelease) run() {
+	r.once.Do(func() { r.root.releaseNamespace(r.prefix) })
+}
+
+var namespaceEscaper = strings.NewReplacer(`\`, `\\`, NamespaceSeparator, `\`+NamespaceSeparator)
+
+// escaped name plus separator, a trailing separator in name is dropped so
+// "users" and "users:" are the same namespace
+func namespacePrefix(name string) string {
+	name = strings.TrimSuffix(name, NamespaceSeparator)
+	return namespaceEscaper.Replace(name) + NamespaceSeparator
+}
+
+// every namespace prefix key could belong to, outermost first
+func namespacePrefixes(key string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for i := 0; i < len(key); i++ {
+			switch key[i] {
+			case '\\':
+				i++
+			case NamespaceSeparator[0]:
+				if !yield(key[:i+1]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Namespace - view of parent where every key is stored under name and
+// NamespaceSeparator. Views of views nest.
+func Namespace[V any](parent Cache[string, V], name string) *NamespaceView[V] {
+	v := &NamespaceView[V]{
+		parent: parent,
+		prefix: namespacePrefix(name),
+		events: &eventHub[string, V]{},
+	}
+
+	switch p := parent.(type) {
+	case *NamespaceView[V]:
+		if p.root != nil {
+			v.root = p.root
+			v.rootPrefix = p.rootPrefix + v.prefix
+		}
+	case namespaceRoot:
+		v.root = p
+		v.rootPrefix = v.prefix
+	}
+
+	if v.root != nil {
+		v.root.acquireNamespace(v.rootPrefix)
+		v.release = &namespaceRelease{root: v.root, prefix: v.rootPrefix}
+		// views that are never closed still unregister
+		runtime.AddCleanup(v, (*namespaceRelease).run, v.release)
+	}
+	return v
+}
+
+func (v *NamespaceView[V]) key(key string) string {
+	return v.prefix + key
+}
+
+// strips the prefix, reports false for keys outside the namespace
+func (v *NamespaceView[V]) local(key string) (string, bool) {
+	return strings.CutPrefix(key, v.prefix)
+}
+
+func (v *NamespaceView[V]) record(hit bool) {
+	if hit {
+		atomic.AddInt64(&v.hits, 1)
+	} else {
+		atomic.AddInt64(&v.misses, 1)
+	}
+}
+
+func (v *NamespaceView[V]) Get(key string) (V, bool) {
+	value, ok := v.parent.Get(v.key(key))
+	v.record(ok)
+	return value, ok
+}
+
+func (v *NamespaceView[V]) Set(key string, value V) bool {
+	return v.parent.Set(v.key(key), value)
+}
+
+func (v *NamespaceView[V]) SetWithTTL(key string, value V, ttl time.Duration) bool {
+	return v.parent.SetWithTTL(v.key(key), value, ttl)
+}
+
+func (v *NamespaceView[V]) Delete(key string) bool {
+	return v.parent.Delete(v.key(key))
+}
+
+// Clear - same as ClearNamespace
+func (v *NamespaceView[V]) Clear() {
+	v.ClearNamespace()
+}
+
+// ClearNamespace - removes this namespace's keys and resets its stats,
+// other namespaces and the backing store are left alone
+func (v *NamespaceView[V]) ClearNamespace() {
+	// invalidate on the root itself, a nested view's parent would pass
+	// deletes through to the root's Writer
+	if inv, ok := v.root.(invalidator[string]); ok {
+		inv.invalidate(v.root.namespaceKeys(v.rootPrefix))
+	} else if inv, ok := v.parent.(invalidator[string]); ok {
+		inv.invalidate(v.rootKeys())
+	} else {
+		v.parent.DeleteBatch(v.rootKeys())
+	}
+
+	atomic.StoreInt64(&v.hits, 0)
+	atomic.StoreInt64(&v.misses, 0)
+	if v.root != nil {
+		v.root.resetNamespace(v.rootPrefix)
+	}
+}
+
+// keys in the namespace as the parent sees them
+func (v *NamespaceView[V]) rootKeys() []string {
+	var keys []string
+	if v.root != nil {
+		for _, key := range v.root.namespaceKeys(v.rootPrefix) {
+			// the root prefix of a nested view includes our parents' prefixes
+			keys = append(keys, v.prefix+key[len(v.rootPrefix):])
+		}
+		return keys
+	}
+
+	// a parent we don't know, scan it
+	for key := range v.parent.KeysSeq() {
+		if strings.HasPrefix(key, v.prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// number of keys in the namespace, like the parent's Size it may include
+// expired keys not cleaned up yet
+func (v *NamespaceView[V]) Size() int {
+	if v.root != nil {
+		size, _ := v.root.namespaceStats(v.rootPrefix)
+		return size
+	}
+
+	var n int
+	for range v.KeysSeq() {
+		n++
+	}
+	return n
+}
+
+func (v *NamespaceView[V]) Keys() []string {
+	keys := v.rootKeys()
+	for i, key := range keys {
+		keys[i] = key[len(v.prefix):]
+	}
+	return keys
+}
+
+// Stats - namespace hits, misses, evictions and size. Capacity is the
+// parent's, pinned entries aren't tracked per namespace. Evictions are
+// only counted when the root parent is a cache from New.
+func (v *NamespaceView[V]) Stats() Stats {
+	hits := atomic.LoadInt64(&v.hits)
+	misses := atomic.LoadInt64(&v.misses)
+
+	total := hits + misses
+	var hitRatio float64
+	if total > 0 {
+		hitRatio = float64(hits) / float64(total)
+	}
+
+	var evictions int64
+	if v.root != nil {
+		_, evictions = v.root.namespaceStats(v.rootPrefix)
+	}
+
+	return Stats{
+		Hits:      hits,
+		Misses:    misses,
+		Evictions: evictions,
+		Size:      v.Size(),
+		Capacity:  v.parent.Stats().Capacity,
+		HitRatio:  hitRatio,
+	}
+}
+
+func (v *NamespaceView[V]) Contains(key string) bool {
+	return v.parent.Contains(v.key(key))
+}
+
+func (v *NamespaceView[V]) All() iter.Seq2[string, V] {
+	return v.filter(v.parent.All())
+}
+
+func (v *NamespaceView[V]) KeysSeq() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for key := range v.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+func (v *NamespaceView[V]) ValuesSeq() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, value := range v.All() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+func (v *NamespaceView[V]) AllOrdered() iter.Seq2[string, V] {
+	return v.filter(v.parent.AllOrdered())
+}
+
+// keeps entries in the namespace, with the prefix stripped
+func (v *NamespaceView[V]) filter(seq iter.Seq2[string, V]) iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		for key, value := range seq {
+			if local, ok := v.local(key); ok {
+				if !yield(local, value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (v *NamespaceView[V]) SetBatch(items map[string]V) int {
+	prefixed := make(map[string]V, len(items))
+	for key, value := range items {
+		prefixed[v.key(key)] = value
+	}
+	return v.parent.SetBatch(prefixed)
+}
+
+func (v *NamespaceView[V]) GetBatch(keys []string) map[string]V {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = v.key(key)
+	}
+
+	found := v.parent.GetBatch(prefixed)
+	result := make(map[string]V, len(found))
+	for key, value := range found {
+		local, _ := v.local(key)
+		result[local] = value
+	}
+
+	atomic.AddInt64(&v.hits, int64(len(found)))
+	atomic.AddInt64(&v.misses, int64(len(keys)-len(found)))
+	return result
+}
+
+func (v *NamespaceView[V]) DeleteBatch(keys []string) int {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = v.key(key)
+	}
+	return v.parent.DeleteBatch(prefixed)
+}
+
+func (v *NamespaceView[V]) SetWithPriority(key string, value V, ttl time.Duration, priority Priority) bool {
+	return v.parent.SetWithPriority(v.key(key), value, ttl, priority)
+}
+
+func (v *NamespaceView[V]) Peek(key string) (V, bool) {
+	return v.parent.Peek(v.key(key))
+}
+
+func (v *NamespaceView[V]) GetWithExpiry(key string) (V, time.Time, bool) {
+	value, expiresAt, ok := v.parent.GetWithExpiry(v.key(key))
+	v.record(ok)
+	return value, expiresAt, ok
+}
+
+func (v *NamespaceView[V]) Touch(key string) bool {
+	return v.parent.Touch(v.key(key))
+}
+
+func (v *NamespaceView[V]) Expire(key string, ttl time.Duration) bool {
+	return v.parent.Expire(v.key(key), ttl)
+}
+
+func (v *NamespaceView[V]) Persist(key string) bool {
+	return v.parent.Persist(v.key(key))
+}
+
+func (v *NamespaceView[V]) GetOrSet(key string, value V) (V, bool, error) {
+	actual, loaded, err := v.parent.GetOrSet(v.key(key), value)
+	v.record(loaded)
+	return actual, loaded, err
+}
+
+func (v *NamespaceView[V]) SetIfAbsent(key string, value V) bool {
+	return v.parent.SetIfAbsent(v.key(key), value)
+}
+
+func (v *NamespaceView[V]) Replace(key string, value V) bool {
+	return v.parent.Replace(v.key(key), value)
+}
+
+func (v *NamespaceView[V]) CompareAndSwap(key string, old, value V, equal func(a, b V) bool) bool {
+	return v.parent.CompareAndSwap(v.key(key), old, value, equal)
+}
+
+func (v *NamespaceView[V]) CompareAndDelete(key string, old V, equal func(a, b V) bool) bool {
+	return v.parent.CompareAndDelete(v.key(key), old, equal)
+}
+
+func (v *NamespaceView[V]) Compute(key string, fn func(old V, exists bool) (V, ComputeAction)) (V, bool) {
+	return v.parent.Compute(v.key(key), fn)
+}
+
+// tags are namespaced too
+func (v *NamespaceView[V]) SetWithTags(key string, value V, ttl time.Duration, tags ...string) bool {
+	prefixed := make([]string, len(tags))
+	for i, tag := range tags {
+		prefixed[i] = v.key(tag)
+	}
+	return v.parent.SetWithTags(v.key(key), value, ttl, prefixed...)
+}
+
+func (v *NamespaceView[V]) InvalidateTag(tag string) int {
+	return v.parent.InvalidateTag(v.key(tag))
+}
+
+func (v *NamespaceView[V]) DependsOn(key string, deps ...string) error {
+	prefixed := make([]string, len(deps))
+	for i, dep := range deps {
+		prefixed[i] = v.key(dep)
+	}
+	return v.parent.DependsOn(v.key(key), prefixed...)
+}
+
+func (v *NamespaceView[V]) RemoveDependency(key string, deps ...string) {
+	prefixed := make([]string, len(deps))
+	for i, dep := range deps {
+		prefixed[i] = v.key(dep)
+	}
+	v.parent.RemoveDependency(v.key(key), prefixed...)
+}
+
+// parent's ordered queries, ErrUnorderedStorage if it has none
+func (v *NamespaceView[V]) ordered() (OrderedCache[string, V], error) {
+	parent, ok := v.parent.(OrderedCache[string, V])
+	if !ok {
+		return nil, ErrUnorderedStorage
+	}
+	return parent, nil
+}
+
+func (v *NamespaceView[V]) Range(from, to string) (iter.Seq2[string, V], error) {
+	parent, err := v.ordered()
+	if err != nil {
+		return nil, err
+	}
+	seq, err := parent.Range(v.key(from), v.key(to))
+	if err != nil {
+		return nil, err
+	}
+	return v.filter(seq), nil
+}
+
+func (v *NamespaceView[V]) ScanPrefix(prefix string) (iter.Seq2[string, V], error) {
+	parent, err := v.ordered()
+	if err != nil {
+		return nil, err
+	}
+	seq, err := parent.ScanPrefix(v.key(prefix))
+	if err != nil {
+		return nil, err
+	}
+	return v.filter(seq), nil
+}
+
+func (v *NamespaceView[V]) DeletePrefix(prefix string) (int, error) {
+	parent, err := v.ordered()
+	if err != nil {
+		return 0, err
+	}
+	return parent.DeletePrefix(v.key(prefix))
+}
+
+// parent's scans, ErrScanUnsupported if it has none
+func (v *NamespaceView[V]) scannable() (ScannableCache[string, V], error) {
+	parent, ok := v.parent.(ScannableCache[string, V])
+	if !ok {
+		return nil, ErrScanUnsupported
+	}
+	return parent, nil
+}
+
+func (v *NamespaceView[V]) Scan(cursor uint64, pattern string, count int) ([]string, uint64, error) {
+	parent, err := v.scannable()
+	if err != nil {
+		return nil, 0, err
+	}
+	if pattern == "" {
+		pattern = "*"
+	}
+	keys, next, err := parent.Scan(cursor, escapeGlob(v.prefix)+pattern, count)
+	for i, key := range keys {
+		keys[i], _ = v.local(key)
+	}
+	return keys, next, err
+}
+
+func (v *NamespaceView[V]) DeleteMatching(pattern string) (int, error) {
+	parent, err := v.scannable()
+	if err != nil {
+		return 0, err
+	}
+	if pattern == "" {
+		pattern = "*"
+	}
+	return parent.DeleteMatching(escapeGlob(v.prefix) + pattern)
+}
+
+// Begin - starts a transaction on the parent that prefixes keys
+func (v *NamespaceView[V]) Begin() *Txn[string, V] {
+	t := v.parent.Begin()
+	inner := t.mapKey
+	t.mapKey = func(key string) string {
+		key = v.key(key)
+		if inner != nil {
+			return inner(key)
+		}
+		return key
+	}
+	return t
+}
+
+// Snapshot - dumps this namespace's entries with local keys
+func (v *NamespaceView[V]) Snapshot(w io.Writer) error {
+	return v.snapshot(w, nil)
+}
+
+// Restore - loads a snapshot into this namespace
+func (v *NamespaceView[V]) Restore(r io.Reader) error {
+	return v.restore(r, nil)
+}
+
+func (v *NamespaceView[V]) snapshot(w io.Writer, keep func(string) (string, bool)) error {
+	s, ok := v.parent.(snapshotter[string, V])
+	if !ok {
+		return ErrSnapshotUnsupported
+	}
+	return s.snapshot(w, func(key string) (string, bool) {
+		local, ok := v.local(key)
+		if !ok || keep == nil {
+			return local, ok
+		}
+		return keep(local)
+	})
+}
+
+func (v *NamespaceView[V]) restore(r io.Reader, mapKey func(string) string) error {
+	s, ok := v.parent.(snapshotter[string, V])
+	if !ok {
+		return ErrSnapshotUnsupported
+	}
+	return s.restore(r, func(key string) string {
+		if mapKey != nil {
+			key = mapKey(key)
+		}
+		return v.key(key)
+	})
+}
+
+func (v *NamespaceView[V]) SetPinned(key string, value V, ttl time.Duration) bool {
+	return v.parent.SetPinned(v.key(key), value, ttl)
+}
+
+func (v *NamespaceView[V]) Unpin(key string) bool {
+	return v.parent.Unpin(v.key(key))
+}
+
+// Subscribe - streams this namespace's events with the prefix stripped.
+// The view's events come from one DropOnFull subscription on the parent, so
+// a slow view subscriber never stalls parent writes: BlockOnFull only makes
+// it block that forwarder, which then drops events once its own buffer fills
+func (v *NamespaceView[V]) Subscribe(buffer int, filter EventFilter[string, V], delivery Delivery) *Subscription[string, V] {
+	v.forwardMu.Lock()
+	if v.forward == nil {
+		v.forward = v.parent.Subscribe(namespaceForwardBuffer, func(ev Event[string, V]) bool {
+			return strings.HasPrefix(ev.Key, v.prefix)
+		}, DropOnFull)
+		go v.forwardEvents(v.forward)
+	}
+	v.forwardMu.Unlock()
+
+	return v.events.subscribe(buffer, filter, delivery)
+}
+
+// events a view's forwarder holds before dropping them
+const namespaceForwardBuffer = 1024
+
+func (v *NamespaceView[V]) forwardEvents(sub *Subscription[string, V]) {
+	for ev := range sub.C() {
+		ev.Key, _ = v.local(ev.Key)
+		v.events.publishEvent(ev)
+	}
+	v.events.close()
+}
+
+// Close - stops the view's subscriptions and key tracking, the parent stays open
+func (v *NamespaceView[V]) Close() {
+	if v.release != nil {
+		v.release.run()
+	}
+
+	v.forwardMu.Lock()
+	forward := v.forward
+	v.forwardMu.Unlock()
+
+	if forward != nil {
+		forward.Close()
+	} else {
+		v.events.close()
+	}
+}
+
+// namespace key sets, kept under the cache lock
+type namespaceIndex struct {
+	refs      int
+	keys      map[string]struct{}
+	evictions int64
+}
+
+func (c *cache[K, V]) acquireNamespace(prefix string) {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if idx, exists := c.namespaces[prefix]; exists {
+		idx.refs++
+		return
+	}
+
+	// one scan for keys stored before the first view
+	idx := &namespaceIndex{refs: 1, keys: make(map[string]struct{})}
+	for _, key := range c.storage.Keys() {
+		if k, ok := any(key).(string); ok && strings.HasPrefix(k, prefix) {
+			idx.keys[k] = struct{}{}
+		}
+	}
+	if c.namespaces == nil {
+		c.namespaces = make(map[string]*namespaceIndex)
+	}
+	c.namespaces[prefix] = idx
+}
+
+func (c *cache[K, V]) releaseNamespace(prefix string) {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if idx, exists := c.namespaces[prefix]; exists {
+		idx.refs--
+		if idx.refs <= 0 {
+			delete(c.namespaces, prefix)
+		}
+	}
+}
+
+func (c *cache[K, V]) namespaceStats(prefix string) (int, int64) {
+	if c.threadSafe {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+
+	idx, exists := c.namespaces[prefix]
+	if !exists {
+		return 0, 0
+	}
+	return len(idx.keys), idx.evictions
+}
+
+func (c *cache[K, V]) namespaceKeys(prefix string) []string {
+	if c.threadSafe {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+
+	idx, exists := c.namespaces[prefix]
+	if !exists {
+		return nil
+	}
+	keys := make([]string, 0, len(idx.keys))
+	for key := range idx.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c *cache[K, V]) resetNamespace(prefix string) {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if idx, exists := c.namespaces[prefix]; exists {
+		idx.evictions = 0
+	}
+}
+
+// adds a newly stored key to its namespaces (assumes lock held)
+func (c *cache[K, V]) namespaceAdd(key K) {
+	if len(c.namespaces) == 0 {
+		return
+	}
+	k, _ := any(key).(string)
+	for prefix := range namespacePrefixes(k) {
+		if idx, exists := c.namespaces[prefix]; exists {
+			idx.keys[k] = struct{}{}
+		}
+	}
+}
+
+// removes a key from its namespaces (assumes lock held)
+func (c *cache[K, V]) namespaceRemove(key K, reason RemovalReason) {
+	if len(c.namespaces) == 0 {
+		return
+	}
+	k, _ := any(key).(string)
+	for prefix := range namespacePrefixes(k) {
+		if idx, exists := c.namespaces[prefix]; exists {
+			delete(idx.keys, k)
+			if reason == ReasonEvicted {
+				idx.evictions++
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"caching-lib/storage"
+	"errors"
+	"iter"
+	"reflect"
+	"strings"
+)
+
+// ErrUnorderedStorage - the storage backend can't walk keys in order
+var ErrUnorderedStorage = errors.New("cache: storage is not ordered")
+
+// ErrNonStringKeys - prefix and pattern queries need string keys
+var ErrNonStringKeys = errors.New("cache: keys are not strings")
+
+// Range - iterates live entries with from <= key < to in key order. Needs
+// an ordered storage backend. Same locking as All.
+func (c *cache[K, V]) Range(from, to K) (iter.Seq2[K, V], error) {
+	ordered, ok := c.storage.(storage.OrderedStorage[K, V])
+	if !ok {
+		return nil, ErrUnorderedStorage
+	}
+
+	return func(yield func(K, V) bool) {
+		if c.threadSafe {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+		}
+
+		for key, item := range ordered.Range(from, to) {
+			if item.IsExpired() {
+				continue
+			}
+			if !yield(key, item.Value) {
+				return
+			}
+		}
+	}, nil
+}
+
+// ScanPrefix - iterates live entries whose key starts with prefix in key
+// order. Needs string keys and an ordered storage backend. Same locking as All.
+func (c *cache[K, V]) ScanPrefix(prefix K) (iter.Seq2[K, V], error) {
+	ordered, err := c.prefixStorage(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(K, V) bool) {
+		if c.threadSafe {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+		}
+
+		for key, item := range prefixed(ordered, prefix) {
+			if item.IsExpired() {
+				continue
+			}
+			if !yield(key, item.Value) {
+				return
+			}
+		}
+	}, nil
+}
+
+// DeletePrefix - deletes every key starting with prefix, like DeleteBatch
+func (c *cache[K, V]) DeletePrefix(prefix K) (int, error) {
+	ordered, err := c.prefixStorage(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	var keys []K
+	for key := range prefixed(ordered, prefix) {
+		keys = append(keys, key)
+	}
+	return c.deleteBatchLocked(keys), nil
+}
+
+func (c *cache[K, V]) prefixStorage(prefix K) (storage.OrderedStorage[K, V], error) {
+	ordered, ok := c.storage.(storage.OrderedStorage[K, V])
+	if !ok {
+		return nil, ErrUnorderedStorage
+	}
+	if _, ok := keyString(prefix); !ok {
+		return nil, ErrNonStringKeys
+	}
+	return ordered, nil
+}
+
+// keys sharing a prefix sit next to each other, so stop at the first miss
+func prefixed[K comparable, V any](ordered storage.OrderedStorage[K, V], prefix K) iter.Seq2[K, *storage.Item[V]] {
+	p, _ := keyString(prefix)
+	return func(yield func(K, *storage.Item[V]) bool) {
+		for key, item := range ordered.Ascend(prefix) {
+			s, _ := keyString(key)
+			if !strings.HasPrefix(s, p) || !yield(key, item) {
+				return
+			}
+		}
+	}
+}
+
+// string form of string-kinded keys, including named string types
+func keyString[K comparable](key K) (string, bool) {
+	if s, ok := any(key).(string); ok {
+		return s, true
+	}
+	v := reflect.ValueOf(key)
+	if v.Kind() != reflect.String {
+		return "", false
+	}
+	return v.String(), true
+}
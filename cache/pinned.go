@@ -0,0 +1,42 @@
+package cache
+
+import "time"
+
+// SetPinned - stores value excluded from eviction, still removable by Delete and TTL
+func (c *cache[K, V]) SetPinned(key K, value V, ttl time.Duration) bool {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	_, wasPinned := c.pinned[key]
+	c.pinned[key] = struct{}{}
+	if !c.setLocked(key, value, ttl, PriorityNormal) {
+		if !wasPinned {
+			delete(c.pinned, key)
+		}
+		return false
+	}
+
+	// drop any tracking from before it was pinned
+	c.policyFor(key).Remove(key)
+	return true
+}
+
+// Unpin - makes a pinned key evictable again
+func (c *cache[K, V]) Unpin(key K) bool {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if _, pinned := c.pinned[key]; !pinned {
+		return false
+	}
+
+	delete(c.pinned, key)
+	if _, exists := c.storage.Peek(key); exists {
+		c.policyFor(key).Access(key)
+	}
+	return true
+}
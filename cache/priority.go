@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"caching-lib/eviction"
+	"time"
+)
+
+// Priority - eviction class, lower classes are evicted first
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+
+	numPriorities = int(PriorityHigh) + 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+func (p Priority) valid() bool {
+	return p >= PriorityLow && p <= PriorityHigh
+}
+
+// SetWithPriority - stores with TTL in the given eviction class
+func (c *cache[K, V]) SetWithPriority(key K, value V, ttl time.Duration, priority Priority) bool {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	return c.setLocked(key, value, ttl, priority)
+}
+
+// policy tracking key's class
+func (c *cache[K, V]) policyFor(key K) eviction.Policy[K] {
+	return c.classPolicy(c.priorityOf(key))
+}
+
+// class key is currently stored in
+func (c *cache[K, V]) priorityOf(key K) Priority {
+	if p, exists := c.priorities[key]; exists {
+		return p
+	}
+	return PriorityNormal
+}
+
+// policy for a class, created on first use
+func (c *cache[K, V]) classPolicy(p Priority) eviction.Policy[K] {
+	if c.policies[p] == nil {
+		c.policies[p] = eviction.NewLRUWithConfig[K](c.capacity, c.threadSafe)
+	}
+	return c.policies[p]
+}
+
+// moves key to another class (assumes lock held)
+func (c *cache[K, V]) setPriority(key K, priority Priority) {
+	if !priority.valid() {
+		priority = PriorityNormal
+	}
+
+	old, exists := c.priorities[key]
+	if !exists {
+		old = PriorityNormal
+	}
+	if old == priority {
+		return
+	}
+
+	c.classPolicy(old).Remove(key)
+	if priority == PriorityNormal {
+		delete(c.priorities, key)
+	} else {
+		c.priorities[key] = priority
+	}
+}
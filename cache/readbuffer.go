@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync"
+)
+
+// hits a stripe holds before it asks for a drain
+const readStripeSize = 16
+
+// striped lossy buffer of read hits, replayed to the policy in batches
+type readBuffer[K comparable] struct {
+	stripes []readStripe[K]
+	mask    uint32
+
+	drainNow chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type readStripe[K comparable] struct {
+	mu   sync.Mutex
+	keys [readStripeSize]K
+	n    int
+}
+
+func newReadBuffer[K comparable]() *readBuffer[K] {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n <<= 1
+	}
+
+	return &readBuffer[K]{
+		stripes:  make([]readStripe[K], n),
+		mask:     uint32(n - 1),
+		drainNow: make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// records a hit, dropping it when the stripe is busy or full
+func (b *readBuffer[K]) record(key K) {
+	s := &b.stripes[rand.Uint32()&b.mask]
+	if !s.mu.TryLock() {
+		return
+	}
+	if s.n < readStripeSize {
+		s.keys[s.n] = key
+		s.n++
+	}
+	full := s.n == readStripeSize
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case b.drainNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// hands buffered hits to fn and empties the stripes
+func (b *readBuffer[K]) drain(fn func(K)) {
+	for i := range b.stripes {
+		s := &b.stripes[i]
+		s.mu.Lock()
+		for _, key := range s.keys[:s.n] {
+			fn(key)
+		}
+		clear(s.keys[:s.n])
+		s.n = 0
+		s.mu.Unlock()
+	}
+}
+
+// records a read hit, buffered when enabled (assumes read lock held)
+func (c *cache[K, V]) accessRead(key K) {
+	if c.reads != nil {
+		c.reads.record(key)
+		return
+	}
+	c.access(key)
+}
+
+// replays buffered hits for keys still present (assumes lock held)
+func (c *cache[K, V]) drainReadsLocked() {
+	if c.reads == nil {
+		return
+	}
+	c.reads.drain(func(key K) {
+		if item, exists := c.storage.Peek(key); exists && !item.IsExpired() {
+			c.access(key)
+		}
+	})
+}
+
+// drains full stripes when no writer comes along to do it
+func (c *cache[K, V]) runMaintenance() {
+	defer close(c.reads.done)
+
+	for {
+		select {
+		case <-c.reads.drainNow:
+			c.mu.Lock()
+			c.drainReadsLocked()
+			c.mu.Unlock()
+		case <-c.reads.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package cache
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+)
+
+// ErrScanUnsupported - a namespace's parent can't Scan
+var ErrScanUnsupported = errors.New("cache: scans not supported")
+
+// page size when Scan gets count <= 0
+const defaultScanCount = 10
+
+// keys deleted per write lock by DeleteMatching
+const deleteMatchingBatch = 100
+
+// Scan - returns live keys matching a Redis-style glob pattern ("" means
+// "*") and the cursor for the next call. Start at 0 and stop when the
+// returned cursor is 0. A page holds about count matches but may be shorter,
+// even empty, when few keys match, since each call looks at no more than
+// scanWorkFactor*count keys. Keys present for the whole scan are returned at
+// least once; keys added or removed meanwhile may or may not be. Each call
+// read-locks the cache for one page only and resumes where the last one
+// stopped. The first call builds a key index that writes keep up to date
+// from then on. String keys only.
+func (c *cache[K, V]) Scan(cursor uint64, pattern string, count int) ([]K, uint64, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	return c.scan(cursor, pattern, count)
+}
+
+// DeleteMatching - deletes every key matching a Redis-style glob pattern,
+// like DeleteBatch. Runs as a Scan, deleting each page's matches before
+// reading the next, so no lock is held across the whole keyspace. String
+// keys only.
+func (c *cache[K, V]) DeleteMatching(pattern string) (int, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	var deleted int
+	var cursor uint64
+	for {
+		keys, next, err := c.scan(cursor, pattern, deleteMatchingBatch)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += c.DeleteBatch(keys)
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+// keys looked at per requested match before a page is cut short
+const scanWorkFactor = 10
+
+// buckets start at 1<<scanMinBits and double past scanLoad keys per bucket
+const (
+	scanMinBits = 4
+	scanLoad    = 4
+)
+
+type scanEntry[K comparable] struct {
+	key  K
+	hash uint64
+}
+
+// keys bucketed by the top bits of their hash, so bucket i holds a
+// contiguous hash range and a cursor is just the first hash not yet
+// returned. Cursors don't depend on the bucket count, so growing or
+// resetting the index leaves them valid.
+type scanIndex[K comparable] struct {
+	bits    uint
+	buckets [][]scanEntry[K]
+	size    int
+}
+
+func newScanIndex[K comparable]() *scanIndex[K] {
+	return &scanIndex[K]{bits: scanMinBits, buckets: make([][]scanEntry[K], 1<<scanMinBits)}
+}
+
+func (x *scanIndex[K]) bucket(hash uint64) uint64 {
+	return hash >> (64 - x.bits)
+}
+
+func (x *scanIndex[K]) add(key K, hash uint64) {
+	if x.size >= scanLoad<<x.bits {
+		x.grow()
+	}
+	b := x.bucket(hash)
+	x.buckets[b] = append(x.buckets[b], scanEntry[K]{key: key, hash: hash})
+	x.size++
+}
+
+func (x *scanIndex[K]) remove(key K, hash uint64) {
+	b := x.bucket(hash)
+	entries := x.buckets[b]
+	for i := range entries {
+		if entries[i].key == key {
+			last := len(entries) - 1
+			entries[i] = entries[last]
+			entries[last] = scanEntry[K]{}
+			x.buckets[b] = entries[:last]
+			x.size--
+			return
+		}
+	}
+}
+
+func (x *scanIndex[K]) grow() {
+	old := x.buckets
+	x.bits++
+	x.buckets = make([][]scanEntry[K], 1<<x.bits)
+	for _, entries := range old {
+		for _, e := range entries {
+			b := x.bucket(e.hash)
+			x.buckets[b] = append(x.buckets[b], e)
+		}
+	}
+}
+
+func (x *scanIndex[K]) reset() {
+	x.bits = scanMinBits
+	x.buckets = make([][]scanEntry[K], 1<<scanMinBits)
+	x.size = 0
+}
+
+// indexes a newly stored key once Scan has been used (assumes lock held)
+func (c *cache[K, V]) scanAdd(key K) {
+	if c.scanIdx != nil {
+		c.scanIdx.add(key, c.hasher.Hash(key))
+	}
+}
+
+// assumes lock held
+func (c *cache[K, V]) scanRemove(key K) {
+	if c.scanIdx != nil {
+		c.scanIdx.remove(key, c.hasher.Hash(key))
+	}
+}
+
+// builds the index on first use, under the write lock
+func (c *cache[K, V]) ensureScanIndex() {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	if c.scanIdx != nil {
+		return
+	}
+
+	idx := newScanIndex[K]()
+	for key := range c.storage.All() {
+		idx.add(key, c.hasher.Hash(key))
+	}
+	c.scanIdx = idx
+}
+
+// one page of keys in hash order from cursor
+func (c *cache[K, V]) scan(cursor uint64, pattern string, count int) ([]K, uint64, error) {
+	var zero K
+	if _, ok := keyString(zero); !ok {
+		return nil, 0, ErrNonStringKeys
+	}
+
+	for {
+		if c.threadSafe {
+			c.mu.RLock()
+		}
+		if c.scanIdx == nil {
+			if c.threadSafe {
+				c.mu.RUnlock()
+			}
+			c.ensureScanIndex()
+			continue
+		}
+		keys, next := c.scanPage(cursor, pattern, count)
+		if c.threadSafe {
+			c.mu.RUnlock()
+		}
+		return keys, next, nil
+	}
+}
+
+// walks buckets in hash order, sorting each so a page can stop mid-bucket;
+// keys sharing a hash stay on one page (assumes lock held)
+func (c *cache[K, V]) scanPage(cursor uint64, pattern string, count int) ([]K, uint64) {
+	idx := c.scanIdx
+	work := count * scanWorkFactor
+	if work < count {
+		work = math.MaxInt
+	}
+
+	var keys []K
+	var entries []scanEntry[K]
+	examined := 0
+	for b := idx.bucket(cursor); b < uint64(len(idx.buckets)); b++ {
+		entries = append(entries[:0], idx.buckets[b]...)
+		slices.SortFunc(entries, func(a, b scanEntry[K]) int {
+			return cmp.Compare(a.hash, b.hash)
+		})
+
+		for i, e := range entries {
+			if e.hash < cursor {
+				continue
+			}
+			examined++
+			if item, exists := c.storage.Peek(e.key); exists && !item.IsExpired() {
+				if s, _ := keyString(e.key); globMatch(pattern, s) {
+					keys = append(keys, e.key)
+				}
+			}
+
+			full := len(keys) >= count || examined >= work
+			if full && (i+1 == len(entries) || entries[i+1].hash != e.hash) {
+				if e.hash == math.MaxUint64 {
+					return keys, 0
+				}
+				return keys, e.hash + 1
+			}
+		}
+	}
+	return keys, 0
+}
+
+// Redis glob matching: * ? [abc] [^abc] [a-z] and \ escapes
+func globMatch(pattern, s string) bool {
+	px, sx := 0, 0
+	starPx, starSx := -1, 0
+
+	for sx < len(s) {
+		if px < len(pattern) {
+			switch pattern[px] {
+			case '*':
+				starPx, starSx = px, sx
+				px++
+				continue
+			case '?':
+				px++
+				sx++
+				continue
+			case '[':
+				if end, ok := matchClass(pattern, px, s[sx]); ok {
+					px = end
+					sx++
+					continue
+				}
+			case '\\':
+				// a trailing backslash matches itself
+				lit, width := byte('\\'), 1
+				if px+1 < len(pattern) {
+					lit, width = pattern[px+1], 2
+				}
+				if lit == s[sx] {
+					px += width
+					sx++
+					continue
+				}
+			default:
+				if pattern[px] == s[sx] {
+					px++
+					sx++
+					continue
+				}
+			}
+		}
+
+		// retry from the last star, swallowing one more byte
+		if starPx < 0 {
+			return false
+		}
+		starSx++
+		px, sx = starPx+1, starSx
+	}
+
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+	return px == len(pattern)
+}
+
+// matches c against the class starting at pattern[i], returns the index past ']'
+func matchClass(pattern string, i int, c byte) (int, bool) {
+	i++
+	negate := i < len(pattern) && pattern[i] == '^'
+	if negate {
+		i++
+	}
+
+	matched := false
+	for i < len(pattern) && pattern[i] != ']' {
+		switch {
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			matched = matched || pattern[i+1] == c
+			i += 2
+		case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+			lo, hi := pattern[i], pattern[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (c >= lo && c <= hi)
+			i += 3
+		default:
+			matched = matched || pattern[i] == c
+			i++
+		}
+	}
+	if i < len(pattern) {
+		i++
+	}
+	return i, matched != negate
+}
+
+// escapes glob metacharacters so s matches literally
+func escapeGlob(s string) string {
+	if !strings.ContainsAny(s, `*?[]\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,428 @@
+package cache
+
+import (
+	"caching-lib/hasher"
+	"errors"
+	"io"
+	"iter"
+	"time"
+)
+
+// shard count when NewSharded gets shards <= 0
+const defaultShards = 16
+
+// ErrCrossShard - a dependency between keys that live in different shards
+var ErrCrossShard = errors.New("cache: keys are in different shards")
+
+var _ Cache[string, int] = (*ShardedCache[string, int])(nil)
+
+// ShardedCache - spreads keys over independently locked caches, each with
+// its own storage and policy, so operations on different shards never contend
+type ShardedCache[K comparable, V any] struct {
+	shards []*cache[K, V]
+	mask   uint64
+	hasher hasher.Hasher[K]
+	// shared by the shards, so one subscription sees them all
+	events *eventHub[K, V]
+}
+
+// NewSharded - shards is rounded up to a power of two (16 when <= 0) and
+// lowered until every shard can hold at least one item. The capacity is
+// split evenly over the shards. Each shard gets its own memory storage and a
+// policy from WithShardPolicy (LRU by default), so WithStorage,
+// WithEvictionPolicy and WithPriorityPolicy are ignored. Shards are picked
+// by the WithHasher hasher, which the shards share. Writers and
+// listeners are shared by all shards and must be safe for concurrent use.
+// Dependencies only link keys of the same shard, a WithHasher that keeps
+// related keys together makes them usable.
+func NewSharded[K comparable, V any](shards int, opts ...Option[K, V]) *ShardedCache[K, V] {
+	config := newConfig(opts)
+	if config.Hasher == nil {
+		config.Hasher = hasher.Default[K]()
+	}
+
+	if shards <= 0 {
+		shards = defaultShards
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	for n > 1 && n > config.Capacity {
+		n >>= 1
+	}
+
+	s := &ShardedCache[K, V]{
+		shards: make([]*cache[K, V], n),
+		mask:   uint64(n - 1),
+		hasher: config.Hasher,
+		events: &eventHub[K, V]{},
+	}
+	for i := range s.shards {
+		shardConfig := *config
+		shardConfig.Capacity = config.Capacity / n
+		if i < config.Capacity%n {
+			shardConfig.Capacity++
+		}
+		shardConfig.Storage = nil
+		shardConfig.EvictionPolicy = nil
+		shardConfig.PriorityPolicies = nil
+		if config.ShardPolicy != nil {
+			shardConfig.EvictionPolicy = config.ShardPolicy(shardConfig.Capacity)
+		}
+		s.shards[i] = newCache(&shardConfig)
+		s.shards[i].events = s.events
+	}
+	return s
+}
+
+func (s *ShardedCache[K, V]) index(key K) int {
+	return int(s.hasher.Hash(key) & s.mask)
+}
+
+func (s *ShardedCache[K, V]) shard(key K) *cache[K, V] {
+	return s.shards[s.index(key)]
+}
+
+// Get - retrieves value from the key's shard
+func (s *ShardedCache[K, V]) Get(key K) (V, bool) {
+	return s.shard(key).Get(key)
+}
+
+// Set - stores key-value pair
+func (s *ShardedCache[K, V]) Set(key K, value V) bool {
+	return s.shard(key).Set(key, value)
+}
+
+// SetWithTTL - stores with specific TTL
+func (s *ShardedCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
+	return s.shard(key).SetWithTTL(key, value, ttl)
+}
+
+// Delete - removes key from cache
+func (s *ShardedCache[K, V]) Delete(key K) bool {
+	return s.shard(key).Delete(key)
+}
+
+// Contains - checks if key exists
+func (s *ShardedCache[K, V]) Contains(key K) bool {
+	return s.shard(key).Contains(key)
+}
+
+// Peek - value without touching stats or recency
+func (s *ShardedCache[K, V]) Peek(key K) (V, bool) {
+	return s.shard(key).Peek(key)
+}
+
+// GetOrSet - returns the existing value, or stores and returns value
+func (s *ShardedCache[K, V]) GetOrSet(key K, value V) (V, bool, error) {
+	return s.shard(key).GetOrSet(key, value)
+}
+
+// Clear - removes all items, one shard at a time
+func (s *ShardedCache[K, V]) Clear() {
+	for _, shard := range s.shards {
+		shard.Clear()
+	}
+}
+
+// Size - item count over all shards
+func (s *ShardedCache[K, V]) Size() int {
+	var size int
+	for _, shard := range s.shards {
+		size += shard.Size()
+	}
+	return size
+}
+
+// Keys - keys of all shards
+func (s *ShardedCache[K, V]) Keys() []K {
+	var keys []K
+	for _, shard := range s.shards {
+		keys = append(keys, shard.Keys()...)
+	}
+	return keys
+}
+
+// All - iterates live entries shard by shard, read-locking one shard at a time
+func (s *ShardedCache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, shard := range s.shards {
+			for key, value := range shard.All() {
+				if !yield(key, value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// KeysSeq - iterates live keys, same locking as All
+func (s *ShardedCache[K, V]) KeysSeq() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for key := range s.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// ValuesSeq - iterates live values, same locking as All
+func (s *ShardedCache[K, V]) ValuesSeq() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, value := range s.All() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// AllOrdered - iterates shard by shard, each in eviction order from the
+// entry evicted last. Shards evict independently, so there is no order
+// across them.
+func (s *ShardedCache[K, V]) AllOrdered() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, shard := range s.shards {
+			for key, value := range shard.AllOrdered() {
+				if !yield(key, value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stats - summed over all shards
+func (s *ShardedCache[K, V]) Stats() Stats {
+	var total Stats
+	for _, shard := range s.shards {
+		stats := shard.Stats()
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.Evictions += stats.Evictions
+		total.Size += stats.Size
+		total.Pinned += stats.Pinned
+		total.Capacity += stats.Capacity
+	}
+
+	if lookups := total.Hits + total.Misses; lookups > 0 {
+		total.HitRatio = float64(total.Hits) / float64(lookups)
+	}
+	return total
+}
+
+// ShardStats - stats of each shard, for spotting hot shards
+func (s *ShardedCache[K, V]) ShardStats() []Stats {
+	stats := make([]Stats, len(s.shards))
+	for i, shard := range s.shards {
+		stats[i] = shard.Stats()
+	}
+	return stats
+}
+
+// SetBatch - stores items, one batch per shard
+func (s *ShardedCache[K, V]) SetBatch(items map[K]V) int {
+	groups := make([]map[K]V, len(s.shards))
+	for key, value := range items {
+		i := s.index(key)
+		if groups[i] == nil {
+			groups[i] = make(map[K]V)
+		}
+		groups[i][key] = value
+	}
+
+	var count int
+	for i, group := range groups {
+		if group != nil {
+			count += s.shards[i].SetBatch(group)
+		}
+	}
+	return count
+}
+
+// GetBatch - retrieves keys, one batch per shard
+func (s *ShardedCache[K, V]) GetBatch(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	for i, group := range s.groupKeys(keys) {
+		if group == nil {
+			continue
+		}
+		for key, value := range s.shards[i].GetBatch(group) {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// DeleteBatch - removes keys, one batch per shard
+func (s *ShardedCache[K, V]) DeleteBatch(keys []K) int {
+	var count int
+	for i, group := range s.groupKeys(keys) {
+		if group != nil {
+			count += s.shards[i].DeleteBatch(group)
+		}
+	}
+	return count
+}
+
+// SetWithPriority - stores with TTL in the given eviction class of the key's shard
+func (s *ShardedCache[K, V]) SetWithPriority(key K, value V, ttl time.Duration, priority Priority) bool {
+	return s.shard(key).SetWithPriority(key, value, ttl, priority)
+}
+
+// GetWithExpiry - like Get, also returns the expiry time (zero if no TTL)
+func (s *ShardedCache[K, V]) GetWithExpiry(key K) (V, time.Time, bool) {
+	return s.shard(key).GetWithExpiry(key)
+}
+
+// Touch - bumps recency without reading the value or counting stats
+func (s *ShardedCache[K, V]) Touch(key K) bool {
+	return s.shard(key).Touch(key)
+}
+
+// Expire - sets a new TTL on an existing key, ttl <= 0 expires it now
+func (s *ShardedCache[K, V]) Expire(key K, ttl time.Duration) bool {
+	return s.shard(key).Expire(key, ttl)
+}
+
+// Persist - removes the TTL from an existing key
+func (s *ShardedCache[K, V]) Persist(key K) bool {
+	return s.shard(key).Persist(key)
+}
+
+// SetIfAbsent - stores value only if key is not cached
+func (s *ShardedCache[K, V]) SetIfAbsent(key K, value V) bool {
+	return s.shard(key).SetIfAbsent(key, value)
+}
+
+// Replace - stores value only if key is already cached, keeping its expiry
+func (s *ShardedCache[K, V]) Replace(key K, value V) bool {
+	return s.shard(key).Replace(key, value)
+}
+
+// CompareAndSwap - stores value if the cached value equals old
+func (s *ShardedCache[K, V]) CompareAndSwap(key K, old, value V, equal func(a, b V) bool) bool {
+	return s.shard(key).CompareAndSwap(key, old, value, equal)
+}
+
+// CompareAndDelete - removes key if the cached value equals old
+func (s *ShardedCache[K, V]) CompareAndDelete(key K, old V, equal func(a, b V) bool) bool {
+	return s.shard(key).CompareAndDelete(key, old, equal)
+}
+
+// Compute - runs fn under the lock of the key's shard
+func (s *ShardedCache[K, V]) Compute(key K, fn func(old V, exists bool) (V, ComputeAction)) (V, bool) {
+	return s.shard(key).Compute(key, fn)
+}
+
+// SetWithTags - stores with TTL and replaces the key's tags
+func (s *ShardedCache[K, V]) SetWithTags(key K, value V, ttl time.Duration, tags ...string) bool {
+	return s.shard(key).SetWithTags(key, value, ttl, tags...)
+}
+
+// InvalidateTag - removes tagged keys from every shard, one at a time
+func (s *ShardedCache[K, V]) InvalidateTag(tag string) int {
+	var count int
+	for _, shard := range s.shards {
+		count += shard.InvalidateTag(tag)
+	}
+	return count
+}
+
+// DependsOn - like the unsharded DependsOn, ErrCrossShard if a dep lives in
+// another shard than key
+func (s *ShardedCache[K, V]) DependsOn(key K, deps ...K) error {
+	i := s.index(key)
+	for _, dep := range deps {
+		if s.index(dep) != i {
+			return ErrCrossShard
+		}
+	}
+	return s.shards[i].DependsOn(key, deps...)
+}
+
+// RemoveDependency - drops key's dependencies on deps, or all of them
+func (s *ShardedCache[K, V]) RemoveDependency(key K, deps ...K) {
+	s.shard(key).RemoveDependency(key, deps...)
+}
+
+// Begin - starts a transaction over all shards, Commit locks only the
+// shards it touches
+func (s *ShardedCache[K, V]) Begin() *Txn[K, V] {
+	for _, shard := range s.shards[1:] {
+		shard.trackTombstones()
+	}
+	t := s.shards[0].Begin()
+	t.shards = s.shards
+	t.shardOf = s.index
+	return t
+}
+
+// SetPinned - stores value excluded from eviction in the key's shard
+func (s *ShardedCache[K, V]) SetPinned(key K, value V, ttl time.Duration) bool {
+	return s.shard(key).SetPinned(key, value, ttl)
+}
+
+// Unpin - makes a pinned key evictable again
+func (s *ShardedCache[K, V]) Unpin(key K) bool {
+	return s.shard(key).Unpin(key)
+}
+
+// Subscribe - streams mutations of every shard. Events of one key arrive
+// in order, events of different shards may interleave.
+func (s *ShardedCache[K, V]) Subscribe(buffer int, filter EventFilter[K, V], delivery Delivery) *Subscription[K, V] {
+	return s.events.subscribe(buffer, filter, delivery)
+}
+
+// Snapshot - entries of every shard in one stream, each shard locked a
+// chunk at a time
+func (s *ShardedCache[K, V]) Snapshot(w io.Writer) error {
+	first := s.shards[0]
+	sw, err := newSnapshotWriter(w, first.keyCodec, first.valueCodec)
+	if err != nil {
+		return err
+	}
+	for _, shard := range s.shards {
+		if err := shard.writeSnapshot(sw, nil); err != nil {
+			return err
+		}
+	}
+	return sw.close()
+}
+
+// Restore - loads a snapshot, routing entries to their shards, so it works
+// across shard counts and from an unsharded cache's snapshot
+func (s *ShardedCache[K, V]) Restore(r io.Reader) error {
+	first := s.shards[0]
+	sr, err := newSnapshotReader(r, first.keyCodec, first.valueCodec)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rec, ok, err := sr.next()
+		if err != nil || !ok {
+			return err
+		}
+		s.shard(rec.key).restoreRecord(rec)
+	}
+}
+
+// keys split by shard index
+func (s *ShardedCache[K, V]) groupKeys(keys []K) [][]K {
+	groups := make([][]K, len(s.shards))
+	for _, key := range keys {
+		i := s.index(key)
+		groups[i] = append(groups[i], key)
+	}
+	return groups
+}
+
+// Close - stops every shard's bg work
+func (s *ShardedCache[K, V]) Close() {
+	for _, shard := range s.shards {
+		shard.Close()
+	}
+}
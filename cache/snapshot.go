@@ -0,0 +1,347 @@
+package cache
+
+import (
+	"bufio"
+	"caching-lib/codec"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrSnapshotFormat - the stream doesn't start with a snapshot header
+	ErrSnapshotFormat = errors.New("cache: not a snapshot")
+	// ErrSnapshotVersion - snapshot written by an incompatible format version
+	ErrSnapshotVersion = errors.New("cache: unsupported snapshot version")
+	// ErrSnapshotCorrupt - a record fails its checksum or the stream is cut short
+	ErrSnapshotCorrupt = errors.New("cache: corrupt snapshot")
+	// ErrSnapshotUnsupported - a namespace's parent can't take snapshots
+	ErrSnapshotUnsupported = errors.New("cache: snapshots not supported")
+)
+
+// header: magic, format version, creation time in unix nanos
+const (
+	snapshotMagic      = "CSNP"
+	snapshotVersion    = 1
+	snapshotHeaderSize = 13
+)
+
+// record framing: tag, payload length, payload crc, payload
+const (
+	snapshotEnd   = 0
+	snapshotEntry = 1
+
+	snapshotFrameSize = 9
+	// flags, priority, remaining ttl, key length
+	snapshotPayloadHeader = 14
+	snapshotMaxPayload    = 1 << 30
+
+	snapshotHasTTL = 1 << 0
+	snapshotPinned = 1 << 1
+)
+
+// entries copied per read lock while dumping
+const snapshotChunk = 256
+
+type snapshotRecord[K comparable, V any] struct {
+	key      K
+	value    V
+	ttl      time.Duration
+	hasTTL   bool
+	pinned   bool
+	priority Priority
+}
+
+// snapshot streams for caches that namespace views sit on
+type snapshotter[K comparable, V any] interface {
+	snapshot(w io.Writer, keep func(K) (K, bool)) error
+	restore(r io.Reader, mapKey func(K) K) error
+}
+
+type snapshotWriter[K comparable, V any] struct {
+	w      *bufio.Writer
+	keys   codec.Codec[K]
+	values codec.Codec[V]
+	buf    []byte
+}
+
+// writes the header
+func newSnapshotWriter[K comparable, V any](w io.Writer, keys codec.Codec[K], values codec.Codec[V]) (*snapshotWriter[K, V], error) {
+	sw := &snapshotWriter[K, V]{w: bufio.NewWriter(w), keys: keys, values: values}
+
+	header := make([]byte, snapshotHeaderSize)
+	copy(header, snapshotMagic)
+	header[4] = snapshotVersion
+	binary.BigEndian.PutUint64(header[5:], uint64(time.Now().UnixNano()))
+	if _, err := sw.w.Write(header); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *snapshotWriter[K, V]) write(rec snapshotRecord[K, V]) error {
+	key, err := sw.keys.Encode(rec.key)
+	if err != nil {
+		return fmt.Errorf("cache: encoding key %v: %w", rec.key, err)
+	}
+	value, err := sw.values.Encode(rec.value)
+	if err != nil {
+		return fmt.Errorf("cache: encoding value of %v: %w", rec.key, err)
+	}
+
+	size := snapshotPayloadHeader + len(key) + len(value)
+	sw.buf = slices.Grow(sw.buf[:0], snapshotFrameSize+size)[:snapshotFrameSize+size]
+	frame, payload := sw.buf[:snapshotFrameSize], sw.buf[snapshotFrameSize:]
+
+	var flags byte
+	if rec.hasTTL {
+		flags |= snapshotHasTTL
+	}
+	if rec.pinned {
+		flags |= snapshotPinned
+	}
+	payload[0] = flags
+	payload[1] = byte(rec.priority)
+	binary.BigEndian.PutUint64(payload[2:], uint64(rec.ttl))
+	binary.BigEndian.PutUint32(payload[10:], uint32(len(key)))
+	copy(payload[snapshotPayloadHeader:], key)
+	copy(payload[snapshotPayloadHeader+len(key):], value)
+
+	frame[0] = snapshotEntry
+	binary.BigEndian.PutUint32(frame[1:], uint32(size))
+	binary.BigEndian.PutUint32(frame[5:], crc32.ChecksumIEEE(payload))
+
+	_, err = sw.w.Write(sw.buf)
+	return err
+}
+
+// writes the end marker and flushes
+func (sw *snapshotWriter[K, V]) close() error {
+	if err := sw.w.WriteByte(snapshotEnd); err != nil {
+		return err
+	}
+	return sw.w.Flush()
+}
+
+type snapshotReader[K comparable, V any] struct {
+	r       *bufio.Reader
+	keys    codec.Codec[K]
+	values  codec.Codec[V]
+	created time.Time
+}
+
+// checks the header
+func newSnapshotReader[K comparable, V any](r io.Reader, keys codec.Codec[K], values codec.Codec[V]) (*snapshotReader[K, V], error) {
+	sr := &snapshotReader[K, V]{r: bufio.NewReader(r), keys: keys, values: values}
+
+	header := make([]byte, snapshotHeaderSize)
+	if _, err := io.ReadFull(sr.r, header); err != nil || string(header[:4]) != snapshotMagic {
+		return nil, ErrSnapshotFormat
+	}
+	if header[4] != snapshotVersion {
+		return nil, fmt.Errorf("%w: %d", ErrSnapshotVersion, header[4])
+	}
+	sr.created = time.Unix(0, int64(binary.BigEndian.Uint64(header[5:])))
+	return sr, nil
+}
+
+// next record, false at the end marker. TTLs come back reduced by the time
+// since the snapshot was taken, entries that ran out are skipped.
+func (sr *snapshotReader[K, V]) next() (snapshotRecord[K, V], bool, error) {
+	var rec snapshotRecord[K, V]
+	for {
+		frame := make([]byte, snapshotFrameSize)
+		if _, err := io.ReadFull(sr.r, frame[:1]); err != nil {
+			return rec, false, fmt.Errorf("%w: missing end marker", ErrSnapshotCorrupt)
+		}
+		if frame[0] == snapshotEnd {
+			return rec, false, nil
+		}
+		if frame[0] != snapshotEntry {
+			return rec, false, fmt.Errorf("%w: unknown record type %d", ErrSnapshotCorrupt, frame[0])
+		}
+		if _, err := io.ReadFull(sr.r, frame[1:]); err != nil {
+			return rec, false, fmt.Errorf("%w: truncated record", ErrSnapshotCorrupt)
+		}
+
+		size := binary.BigEndian.Uint32(frame[1:])
+		if size < snapshotPayloadHeader || size > snapshotMaxPayload {
+			return rec, false, fmt.Errorf("%w: bad record size %d", ErrSnapshotCorrupt, size)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(sr.r, payload); err != nil {
+			return rec, false, fmt.Errorf("%w: truncated record", ErrSnapshotCorrupt)
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(frame[5:]) {
+			return rec, false, fmt.Errorf("%w: checksum mismatch", ErrSnapshotCorrupt)
+		}
+
+		keyLen := binary.BigEndian.Uint32(payload[10:])
+		if keyLen > size-snapshotPayloadHeader {
+			return rec, false, fmt.Errorf("%w: bad key length", ErrSnapshotCorrupt)
+		}
+		keyEnd := snapshotPayloadHeader + keyLen
+
+		flags := payload[0]
+		rec.hasTTL = flags&snapshotHasTTL != 0
+		rec.pinned = flags&snapshotPinned != 0
+		rec.priority = Priority(payload[1])
+		if rec.hasTTL {
+			rec.ttl = time.Duration(binary.BigEndian.Uint64(payload[2:])) - time.Since(sr.created)
+			if rec.ttl <= 0 {
+				continue
+			}
+		}
+
+		var err error
+		if rec.key, err = sr.keys.Decode(payload[snapshotPayloadHeader:keyEnd]); err != nil {
+			return rec, false, fmt.Errorf("cache: decoding key: %w", err)
+		}
+		if rec.value, err = sr.values.Decode(payload[keyEnd:]); err != nil {
+			return rec, false, fmt.Errorf("cache: decoding value of %v: %w", rec.key, err)
+		}
+		return rec, true, nil
+	}
+}
+
+// Snapshot - writes live entries with their remaining TTLs, priority and
+// pin state, evicted-next first, using the WithSnapshotCodec codecs. The
+// cache is locked only to read the eviction order and then per chunk of
+// entries, so writes made during the dump may or may not be included.
+func (c *cache[K, V]) Snapshot(w io.Writer) error {
+	return c.snapshot(w, nil)
+}
+
+// Restore - loads a snapshot on top of the current contents. Entries are
+// replayed in eviction order, which rebuilds recency for LRU and FIFO, and
+// the hottest survive when the snapshot is larger than the capacity. TTLs
+// keep counting from when the snapshot was taken. Nothing is written to the
+// backing store. Entries before a corrupt record stay restored.
+func (c *cache[K, V]) Restore(r io.Reader) error {
+	return c.restore(r, nil)
+}
+
+// keep filters and re-keys entries, nil keeps all
+func (c *cache[K, V]) snapshot(w io.Writer, keep func(K) (K, bool)) error {
+	sw, err := newSnapshotWriter(w, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+	if err := c.writeSnapshot(sw, keep); err != nil {
+		return err
+	}
+	return sw.close()
+}
+
+// streams entries, locking one chunk at a time
+func (c *cache[K, V]) writeSnapshot(sw *snapshotWriter[K, V], keep func(K) (K, bool)) error {
+	if c.threadSafe {
+		c.mu.Lock()
+	}
+	// buffered hits first, so the order is current
+	c.drainReadsLocked()
+	keys := slices.Collect(c.orderedKeys())
+	if c.threadSafe {
+		c.mu.Unlock()
+	}
+	slices.Reverse(keys)
+
+	records := make([]snapshotRecord[K, V], 0, snapshotChunk)
+	for chunk := range slices.Chunk(keys, snapshotChunk) {
+		records = c.snapshotRecords(records[:0], chunk, keep)
+		for _, rec := range records {
+			if err := sw.write(rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copies out entries still live
+func (c *cache[K, V]) snapshotRecords(records []snapshotRecord[K, V], keys []K, keep func(K) (K, bool)) []snapshotRecord[K, V] {
+	if c.threadSafe {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+
+	for _, key := range keys {
+		item, exists := c.storage.Peek(key)
+		if !exists || item.IsExpired() {
+			continue
+		}
+
+		rec := snapshotRecord[K, V]{key: key, value: item.Value, hasTTL: item.HasTTL, priority: c.priorityOf(key)}
+		if keep != nil {
+			var ok bool
+			if rec.key, ok = keep(key); !ok {
+				continue
+			}
+		}
+		if item.HasTTL {
+			rec.ttl = time.Until(item.ExpiresAt)
+			if rec.ttl <= 0 {
+				continue
+			}
+		}
+		_, rec.pinned = c.pinned[key]
+		records = append(records, rec)
+	}
+	return records
+}
+
+// mapKey re-keys entries, nil keeps them
+func (c *cache[K, V]) restore(r io.Reader, mapKey func(K) K) error {
+	sr, err := newSnapshotReader(r, c.keyCodec, c.valueCodec)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rec, ok, err := sr.next()
+		if err != nil || !ok {
+			return err
+		}
+		if mapKey != nil {
+			rec.key = mapKey(rec.key)
+		}
+		c.restoreRecord(rec)
+	}
+}
+
+func (c *cache[K, V]) restoreRecord(rec snapshotRecord[K, V]) {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	ttl := rec.ttl
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+
+	if rec.pinned {
+		c.pinned[rec.key] = struct{}{}
+	} else {
+		delete(c.pinned, rec.key)
+	}
+	c.setPriority(rec.key, rec.priority)
+
+	item := c.itemPool.Get()
+	item.Value = rec.value
+	if rec.hasTTL {
+		item.SetTTL(ttl)
+	} else {
+		item.SetTTL(0)
+	}
+	c.storeLocked(rec.key, item)
+
+	if rec.pinned {
+		// drop any tracking from before it was pinned
+		c.policyFor(rec.key).Remove(rec.key)
+	}
+}
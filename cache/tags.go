@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"slices"
+	"time"
+)
+
+// SetWithTags - stores with TTL and replaces the key's tags. Tags stay with
+// the key across later writes until it leaves the cache.
+func (c *cache[K, V]) SetWithTags(key K, value V, ttl time.Duration, tags ...string) bool {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if !c.setLocked(key, value, ttl, PriorityNormal) {
+		return false
+	}
+	c.untagLocked(key)
+	c.tagLocked(key, tags)
+	return true
+}
+
+// InvalidateTag - removes every key carrying tag from the cache, the
+// backing store is left alone. Returns the number of keys removed.
+func (c *cache[K, V]) InvalidateTag(tag string) int {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	keys := make([]K, 0, len(c.tagKeys[tag]))
+	for key := range c.tagKeys[tag] {
+		keys = append(keys, key)
+	}
+
+	var count int
+	for _, key := range keys {
+		if c.invalidateLocked(key) {
+			count++
+		}
+	}
+	return count
+}
+
+// indexes key under tags (assumes lock held)
+func (c *cache[K, V]) tagLocked(key K, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	tags = slices.Compact(slices.Sorted(slices.Values(tags)))
+	c.keyTags[key] = tags
+	for _, tag := range tags {
+		keys, exists := c.tagKeys[tag]
+		if !exists {
+			keys = make(map[K]struct{})
+			c.tagKeys[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// drops key from the tag index (assumes lock held)
+func (c *cache[K, V]) untagLocked(key K) {
+	tags, exists := c.keyTags[key]
+	if !exists {
+		return
+	}
+
+	delete(c.keyTags, key)
+	for _, tag := range tags {
+		keys := c.tagKeys[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(c.tagKeys, tag)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"caching-lib/storage"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// TieredStats - hits per tier plus traffic between them
+type TieredStats struct {
+	// the L1 cache's own stats, its misses include L2 hits
+	L1 Stats
+
+	L1Hits     int64
+	L2Hits     int64
+	Misses     int64
+	Promotions int64
+	Demotions  int64
+	L2Size     int
+	HitRatio   float64
+}
+
+// TieredCache - a small in-memory cache (L1) in front of a larger storage
+// (L2) such as DiskStorage or ArenaStorage. L1 misses fall through to L2 and
+// promote the entry on a hit; L1 evictions are demoted into L2 with their
+// expiry instead of being dropped, and aren't reported as evictions. A key
+// lives in at most one tier. L2 is only touched under the L1 lock, so the
+// tiers never disagree.
+type TieredCache[K comparable, V any] struct {
+	l1 *cache[K, V]
+	l2 storage.Storage[K, V]
+
+	// 0 for no limit
+	l2Capacity int
+	// last sweep of a full L2 for expired entries (L1 lock held)
+	l2Swept time.Time
+
+	l1Hits     int64
+	l2Hits     int64
+	misses     int64
+	promotions int64
+	demotions  int64
+}
+
+// NewTiered - opts configure L1 (capacity, policy, TTLs, writers,
+// listeners). Nothing is ever evicted from L2, it only shrinks by promotion,
+// Delete and CleanupExpired; WithL2Capacity caps it, after which L1
+// evictions are dropped and reported as usual.
+func NewTiered[K comparable, V any](l2 storage.Storage[K, V], opts ...Option[K, V]) *TieredCache[K, V] {
+	config := newConfig(opts)
+	t := &TieredCache[K, V]{l2: l2, l2Capacity: config.L2Capacity}
+	t.l1 = newCache(config)
+	t.l1.demote = t.demote
+	return t
+}
+
+// how often a full L2 is swept for expired entries to make room
+const l2SweepInterval = time.Second
+
+// evicted from L1, false if dropped (L1 lock held)
+func (t *TieredCache[K, V]) demote(key K, item *storage.Item[V]) bool {
+	if item.IsExpired() {
+		return false
+	}
+	t.l2.Set(key, item)
+	// storages drop writes they fail to make
+	if _, ok := t.l2.Peek(key); !ok {
+		return false
+	}
+	if t.l2Capacity > 0 && t.l2.Size() > t.l2Capacity && !t.sweepL2() {
+		t.l2.Delete(key)
+		return false
+	}
+	atomic.AddInt64(&t.demotions, 1)
+	return true
+}
+
+// drops expired L2 entries at most once per l2SweepInterval, true if L2 is
+// back within capacity
+func (t *TieredCache[K, V]) sweepL2() bool {
+	if time.Since(t.l2Swept) < l2SweepInterval {
+		return false
+	}
+	t.l2Swept = time.Now()
+	t.l2.CleanupExpired()
+	return t.l2.Size() <= t.l2Capacity
+}
+
+// Get - retrieves value from L1, then L2, promoting L2 hits
+func (t *TieredCache[K, V]) Get(key K) (V, bool) {
+	if value, ok := t.l1.Get(key); ok {
+		atomic.AddInt64(&t.l1Hits, 1)
+		return value, true
+	}
+	return t.promote(key)
+}
+
+// moves key from L2 into L1
+func (t *TieredCache[K, V]) promote(key K) (V, bool) {
+	c := t.l1
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	// another reader may have promoted it meanwhile
+	if item, exists := c.lookupLocked(key); exists {
+		c.access(key)
+		atomic.AddInt64(&t.l1Hits, 1)
+		return item.Value, true
+	}
+
+	var zero V
+	found, exists := t.l2.Get(key)
+	if !exists || found.IsExpired() {
+		atomic.AddInt64(&t.misses, 1)
+		return zero, false
+	}
+
+	// copy before Delete, storage may recycle it
+	item := c.itemPool.Get()
+	item.Value = found.Value
+	item.ExpiresAt = found.ExpiresAt
+	item.HasTTL = found.HasTTL
+	value := item.Value
+	t.l2.Delete(key)
+
+	// already in the backing store, so no write
+	c.storeLocked(key, item)
+	atomic.AddInt64(&t.l2Hits, 1)
+	atomic.AddInt64(&t.promotions, 1)
+	return value, true
+}
+
+// Set - stores key-value pair in L1
+func (t *TieredCache[K, V]) Set(key K, value V) bool {
+	return t.SetWithTTL(key, value, t.l1.defaultTTL)
+}
+
+// SetWithTTL - stores with specific TTL in L1, dropping any L2 copy
+func (t *TieredCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
+	c := t.l1
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if !c.setLocked(key, value, ttl, PriorityNormal) {
+		return false
+	}
+	t.l2.Delete(key)
+	return true
+}
+
+// Delete - removes key from both tiers
+func (t *TieredCache[K, V]) Delete(key K) bool {
+	c := t.l1
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	if err := c.write(WriteOp[K, V]{Key: key, Delete: true}); err != nil {
+		return false
+	}
+
+	deleted := c.deleteLocked(key)
+	if item, exists := t.l2.Peek(key); exists {
+		deleted = deleted || !item.IsExpired()
+		t.l2.Delete(key)
+	}
+	return deleted
+}
+
+// Contains - checks if key exists in either tier
+func (t *TieredCache[K, V]) Contains(key K) bool {
+	_, ok := t.Peek(key)
+	return ok
+}
+
+// Peek - value from either tier without promoting or touching stats
+func (t *TieredCache[K, V]) Peek(key K) (V, bool) {
+	c := t.l1
+	if c.threadSafe {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+
+	if item, exists := c.storage.Peek(key); exists && !item.IsExpired() {
+		return item.Value, true
+	}
+	if item, exists := t.l2.Peek(key); exists && !item.IsExpired() {
+		return item.Value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Clear - removes all items from both tiers
+func (t *TieredCache[K, V]) Clear() {
+	t.l1.Clear()
+
+	c := t.l1
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	t.l2.Clear()
+}
+
+// Size - item count over both tiers, L2 may still hold expired items
+func (t *TieredCache[K, V]) Size() int {
+	c := t.l1
+	if c.threadSafe {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+
+	return c.storage.Size() + t.l2.Size()
+}
+
+// Keys - keys of both tiers
+func (t *TieredCache[K, V]) Keys() []K {
+	c := t.l1
+	if c.threadSafe {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+
+	return append(c.storage.Keys(), t.l2.Keys()...)
+}
+
+// CleanupExpired - drops expired items from L2, L1 cleans itself with a default TTL
+func (t *TieredCache[K, V]) CleanupExpired() int {
+	c := t.l1
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	return t.l2.CleanupExpired()
+}
+
+// Stats - hits per tier
+func (t *TieredCache[K, V]) Stats() TieredStats {
+	stats := TieredStats{
+		L1:         t.l1.Stats(),
+		L1Hits:     atomic.LoadInt64(&t.l1Hits),
+		L2Hits:     atomic.LoadInt64(&t.l2Hits),
+		Misses:     atomic.LoadInt64(&t.misses),
+		Promotions: atomic.LoadInt64(&t.promotions),
+		Demotions:  atomic.LoadInt64(&t.demotions),
+		L2Size:     t.l2.Size(),
+	}
+
+	if lookups := stats.L1Hits + stats.L2Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.L1Hits+stats.L2Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// Close - stops L1's bg work and closes L2 if it's an io.Closer. L1
+// contents are not moved to L2, so with a persistent L2 only demoted
+// entries survive a restart.
+func (t *TieredCache[K, V]) Close() error {
+	t.l1.Close()
+	if closer, ok := t.l2.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
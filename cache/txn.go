@@ -0,0 +1,379 @@
+package cache
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTxnConflict - a key read or watched by the transaction changed before Commit
+var ErrTxnConflict = errors.New("cache: transaction conflict")
+
+// ErrTxnDone - the transaction was already committed or discarded
+var ErrTxnDone = errors.New("cache: transaction already finished")
+
+// ErrTxnTooLarge - the transaction sets more keys than the cache holds
+var ErrTxnTooLarge = errors.New("cache: transaction larger than the cache")
+
+// ErrTxnUnbatched - the write-through Writer has no WriteBatch, so a failure
+// partway would leave the store with only some of the writes
+var ErrTxnUnbatched = errors.New("cache: write-through transaction needs a BatchWriter")
+
+// removal versions are kept per stripe of keys, collisions only cause
+// spurious conflicts
+const txnTombstoneStripes = 1024
+
+// Txn - buffered writes applied all-or-nothing on Commit. Keys read through
+// Get or passed to Watch are checked on Commit, like Redis WATCH. Not safe
+// for concurrent use.
+type Txn[K comparable, V any] struct {
+	c *cache[K, V]
+	// every shard of a sharded cache in lock order, nil for a single cache
+	shards  []*cache[K, V]
+	shardOf func(K) int
+	// maps keys for namespaced views, nil means as-is
+	mapKey func(K) K
+
+	watched map[K]txnWatch
+	writes  []txnWrite[K, V]
+	index   map[K]int
+	done    bool
+}
+
+// what a watched key looked like: its item version if present, otherwise
+// the cache version at the time
+type txnWatch struct {
+	version uint64
+	present bool
+}
+
+type txnWrite[K comparable, V any] struct {
+	key    K
+	value  V
+	ttl    time.Duration
+	delete bool
+}
+
+// Begin - starts a transaction
+func (c *cache[K, V]) Begin() *Txn[K, V] {
+	c.trackTombstones()
+	return &Txn[K, V]{
+		c:       c,
+		watched: make(map[K]txnWatch),
+		index:   make(map[K]int),
+	}
+}
+
+func (t *Txn[K, V]) key(key K) K {
+	if t.mapKey != nil {
+		return t.mapKey(key)
+	}
+	return key
+}
+
+// cache holding key
+func (t *Txn[K, V]) cacheOf(key K) *cache[K, V] {
+	if t.shards == nil {
+		return t.c
+	}
+	return t.shards[t.shardOf(key)]
+}
+
+// caches the transaction reads or writes, in lock order
+func (t *Txn[K, V]) caches() []*cache[K, V] {
+	if t.shards == nil {
+		return []*cache[K, V]{t.c}
+	}
+	used := make([]bool, len(t.shards))
+	for key := range t.watched {
+		used[t.shardOf(key)] = true
+	}
+	for _, w := range t.writes {
+		used[t.shardOf(w.key)] = true
+	}
+
+	var caches []*cache[K, V]
+	for i, shard := range t.shards {
+		if used[i] {
+			caches = append(caches, shard)
+		}
+	}
+	return caches
+}
+
+// buffered writes to keys held by c
+func (t *Txn[K, V]) writesIn(c *cache[K, V]) []txnWrite[K, V] {
+	if t.shards == nil {
+		return t.writes
+	}
+	var writes []txnWrite[K, V]
+	for _, w := range t.writes {
+		if t.cacheOf(w.key) == c {
+			writes = append(writes, w)
+		}
+	}
+	return writes
+}
+
+// Get - returns the transaction's own pending write if any, otherwise the
+// cached value, and watches the key. Doesn't touch stats or recency.
+func (t *Txn[K, V]) Get(key K) (V, bool) {
+	key = t.key(key)
+	if i, exists := t.index[key]; exists {
+		w := t.writes[i]
+		if w.delete {
+			var zero V
+			return zero, false
+		}
+		return w.value, true
+	}
+
+	value, watch := t.cacheOf(key).watchOf(key)
+	if _, watched := t.watched[key]; !watched {
+		t.watched[key] = watch
+	}
+	return value, watch.present
+}
+
+// Watch - fails Commit if any of keys changes before it
+func (t *Txn[K, V]) Watch(keys ...K) {
+	for _, key := range keys {
+		key = t.key(key)
+		if _, watched := t.watched[key]; watched {
+			continue
+		}
+		_, t.watched[key] = t.cacheOf(key).watchOf(key)
+	}
+}
+
+// Set - buffers a write with the default TTL
+func (t *Txn[K, V]) Set(key K, value V) {
+	t.SetWithTTL(key, value, t.c.defaultTTL)
+}
+
+// SetWithTTL - buffers a write with a custom TTL
+func (t *Txn[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	t.buffer(txnWrite[K, V]{key: t.key(key), value: value, ttl: ttl})
+}
+
+// Delete - buffers a delete
+func (t *Txn[K, V]) Delete(key K) {
+	t.buffer(txnWrite[K, V]{key: t.key(key), delete: true})
+}
+
+// later writes to the same key replace earlier ones
+func (t *Txn[K, V]) buffer(w txnWrite[K, V]) {
+	if i, exists := t.index[w.key]; exists {
+		t.writes[i] = w
+		return
+	}
+	t.index[w.key] = len(t.writes)
+	t.writes = append(t.writes, w)
+}
+
+// Discard - drops buffered writes
+func (t *Txn[K, V]) Discard() {
+	t.done = true
+	t.writes = nil
+}
+
+// Commit - applies every buffered write under the cache lock, or none of
+// them if a watched key changed, the sets don't fit in the cache or the
+// write-through store failed. Sharded caches lock every shard the
+// transaction touches, in shard order. Write-through stores get the writes as one
+// batch and must implement BatchWriter, otherwise Commit fails with
+// ErrTxnUnbatched; write-behind queues them like any other write. Room is
+// made by evicting keys outside the transaction, so its own writes never
+// evict each other.
+func (t *Txn[K, V]) Commit() error {
+	if t.done {
+		return ErrTxnDone
+	}
+	t.done = true
+
+	caches := t.caches()
+	for _, c := range caches {
+		if c.threadSafe {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+		}
+	}
+
+	for key, watch := range t.watched {
+		if t.cacheOf(key).changedLocked(key, watch) {
+			return ErrTxnConflict
+		}
+	}
+
+	for _, c := range caches {
+		sets := 0
+		for _, w := range t.writesIn(c) {
+			if !w.delete {
+				sets++
+			}
+		}
+		if sets > c.capacity {
+			return ErrTxnTooLarge
+		}
+	}
+
+	if err := t.writeLocked(caches); err != nil {
+		return err
+	}
+
+	// deletes first so they free room before sets evict anything
+	for _, w := range t.writes {
+		if w.delete {
+			t.cacheOf(w.key).deleteLocked(w.key)
+		}
+	}
+	for _, c := range caches {
+		t.makeRoomLocked(c)
+	}
+	for _, w := range t.writes {
+		if !w.delete {
+			t.cacheOf(w.key).storeTxnLocked(w.key, w.value, w.ttl)
+		}
+	}
+	return nil
+}
+
+// one batch for a write-through store, shards share its writer; write-behind
+// queues each shard's writes on its own queue (assumes locks held)
+func (t *Txn[K, V]) writeLocked(caches []*cache[K, V]) error {
+	if _, batched := t.c.batchWriter(); batched || t.shards == nil {
+		return t.c.writeTxn(t.writes)
+	}
+	for _, c := range caches {
+		if err := c.writeTxn(t.writesIn(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evicts enough keys of c outside the transaction for its new keys
+// (assumes lock held)
+func (t *Txn[K, V]) makeRoomLocked(c *cache[K, V]) {
+	// victims are picked from recency that includes buffered hits
+	c.drainReadsLocked()
+
+	added := 0
+	for _, w := range t.writesIn(c) {
+		if _, exists := c.storage.Peek(w.key); !w.delete && !exists {
+			added++
+		}
+	}
+
+	// skipped keys are all about to be stored, which tracks them again
+	ours := func(key K) bool {
+		i, exists := t.index[key]
+		return exists && !t.writes[i].delete
+	}
+	for excess := c.storage.Size() + added - c.capacity; excess > 0; excess-- {
+		key, ok := c.evictCandidate(ours)
+		if !ok {
+			return
+		}
+		c.dropLocked(key, ReasonEvicted)
+		atomic.AddInt64(&c.evictions, 1)
+	}
+}
+
+// sends a transaction's writes to the backing store (assumes lock held)
+func (c *cache[K, V]) writeTxn(writes []txnWrite[K, V]) error {
+	if c.writer == nil || len(writes) == 0 {
+		return nil
+	}
+
+	ops := make([]WriteOp[K, V], len(writes))
+	for i, w := range writes {
+		ops[i] = WriteOp[K, V]{Key: w.key, Value: w.value, Delete: w.delete}
+	}
+
+	if bw, ok := c.batchWriter(); ok {
+		return bw.WriteBatch(ops)
+	}
+	if c.behind == nil {
+		return ErrTxnUnbatched
+	}
+	for _, op := range ops {
+		if err := c.write(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setLocked without the write, the store already has it (assumes lock held)
+func (c *cache[K, V]) storeTxnLocked(key K, value V, ttl time.Duration) {
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+
+	item := c.itemPool.Get()
+	item.Value = value
+	item.SetTTL(ttl)
+	c.storeLocked(key, item)
+}
+
+// live value of key and what Commit compares against
+func (c *cache[K, V]) watchOf(key K) (V, txnWatch) {
+	if c.threadSafe {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+	}
+
+	var zero V
+	item, exists := c.storage.Peek(key)
+	if !exists || item.IsExpired() {
+		return zero, txnWatch{version: c.version}
+	}
+	return item.Value, txnWatch{version: item.Version, present: true}
+}
+
+// whether key was written or removed since watch (assumes lock held)
+func (c *cache[K, V]) changedLocked(key K, watch txnWatch) bool {
+	item, exists := c.storage.Peek(key)
+	if exists && !item.IsExpired() {
+		return !watch.present || item.Version != watch.version
+	}
+	if watch.present {
+		return true
+	}
+	// absent both times, but it may have come and gone in between
+	return c.tombstones[c.tombstoneStripe(key)] > watch.version
+}
+
+// starts recording removals for transactions
+func (c *cache[K, V]) trackTombstones() {
+	if c.threadSafe {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	if c.tombstones == nil {
+		c.tombstones = make([]uint64, txnTombstoneStripes)
+	}
+}
+
+func (c *cache[K, V]) tombstoneStripe(key K) uint64 {
+	return c.hasher.Hash(key) % txnTombstoneStripes
+}
+
+// records a removal of key (assumes lock held)
+func (c *cache[K, V]) tombstoneLocked(key K) {
+	if c.tombstones != nil {
+		c.tombstones[c.tombstoneStripe(key)] = c.nextVersion()
+	}
+}
+
+// assumes lock held
+func (c *cache[K, V]) tombstoneAllLocked() {
+	if c.tombstones == nil {
+		return
+	}
+	version := c.nextVersion()
+	for i := range c.tombstones {
+		c.tombstones[i] = version
+	}
+}
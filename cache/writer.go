@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Writer - backing store that receives cache changes
+type Writer[K comparable, V any] interface {
+	Write(key K, value V) error
+	Delete(key K) error
+}
+
+// BatchWriter - optional Writer extension, used for batch ops and write-behind flushes
+type BatchWriter[K comparable, V any] interface {
+	Writer[K, V]
+	WriteBatch(ops []WriteOp[K, V]) error
+}
+
+// WriteOp - single change sent to a backing store
+type WriteOp[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Delete bool
+}
+
+// WriteMode - how changes reach the backing store
+type WriteMode int
+
+const (
+	// WriteThrough - writes synchronously, failing the cache op on error
+	WriteThrough WriteMode = iota
+	// WriteBehind - queues writes and flushes them in the background
+	WriteBehind
+)
+
+// sends a single op to the writer
+func applyWriteOp[K comparable, V any](w Writer[K, V], op WriteOp[K, V]) error {
+	if op.Delete {
+		return w.Delete(op.Key)
+	}
+	return w.Write(op.Key, op.Value)
+}
+
+// sends ops to the writer, batched if supported
+func applyWriteOps[K comparable, V any](w Writer[K, V], ops []WriteOp[K, V]) error {
+	if bw, ok := w.(BatchWriter[K, V]); ok {
+		return bw.WriteBatch(ops)
+	}
+	for _, op := range ops {
+		if err := applyWriteOp(w, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write-behind queue, coalesces repeated writes to the same key
+type writeBehind[K comparable, V any] struct {
+	writer    Writer[K, V]
+	interval  time.Duration
+	batchSize int
+	onError   func(error)
+
+	mu      sync.Mutex
+	pending []WriteOp[K, V]
+	index   map[K]int
+	closed  bool
+
+	// serializes flushes so ops reach the writer in order
+	flushMu sync.Mutex
+
+	flushNow chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newWriteBehind[K comparable, V any](w Writer[K, V], interval time.Duration, batchSize int, onError func(error)) *writeBehind[K, V] {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	wb := &writeBehind[K, V]{
+		writer:    w,
+		interval:  interval,
+		batchSize: batchSize,
+		onError:   onError,
+		index:     make(map[K]int, batchSize),
+		flushNow:  make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go wb.run()
+	return wb
+}
+
+// queues op, replacing any pending op for the same key. False once
+// closed, the caller must write op itself.
+func (wb *writeBehind[K, V]) enqueue(op WriteOp[K, V]) bool {
+	wb.mu.Lock()
+	if wb.closed {
+		wb.mu.Unlock()
+		return false
+	}
+	if i, exists := wb.index[op.Key]; exists {
+		wb.pending[i] = op
+	} else {
+		wb.index[op.Key] = len(wb.pending)
+		wb.pending = append(wb.pending, op)
+	}
+	full := len(wb.pending) >= wb.batchSize
+	wb.mu.Unlock()
+
+	if full {
+		select {
+		case wb.flushNow <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// puts failed ops back in front of the queue, unless a newer op for the
+// same key arrived meanwhile
+func (wb *writeBehind[K, V]) requeue(ops []WriteOp[K, V]) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	retry := make([]WriteOp[K, V], 0, len(ops)+len(wb.pending))
+	for _, op := range ops {
+		if _, newer := wb.index[op.Key]; !newer {
+			retry = append(retry, op)
+		}
+	}
+	retry = append(retry, wb.pending...)
+
+	wb.pending = retry
+	clear(wb.index)
+	for i, op := range retry {
+		wb.index[op.Key] = i
+	}
+}
+
+func (wb *writeBehind[K, V]) run() {
+	ticker := time.NewTicker(wb.interval)
+	defer ticker.Stop()
+	defer close(wb.done)
+
+	for {
+		select {
+		case <-ticker.C:
+			wb.flush(false)
+		case <-wb.flushNow:
+			wb.flush(false)
+		case <-wb.stop:
+			wb.flush(true)
+			return
+		}
+	}
+}
+
+// writes out everything queued so far. A failed batch and the ones after
+// it are requeued for the next flush, except on the final flush at close
+// where there is no next one.
+func (wb *writeBehind[K, V]) flush(final bool) {
+	wb.flushMu.Lock()
+	defer wb.flushMu.Unlock()
+
+	wb.mu.Lock()
+	ops := wb.pending
+	wb.pending = nil
+	wb.index = make(map[K]int, wb.batchSize)
+	wb.mu.Unlock()
+
+	for start := 0; start < len(ops); start += wb.batchSize {
+		end := min(start+wb.batchSize, len(ops))
+		err := applyWriteOps(wb.writer, ops[start:end])
+		if err == nil {
+			continue
+		}
+		if wb.onError != nil {
+			wb.onError(err)
+		}
+		if !final {
+			wb.requeue(ops[start:])
+			return
+		}
+	}
+}
+
+// stops the flush loop after draining the queue, later ops are refused
+func (wb *writeBehind[K, V]) close() {
+	wb.mu.Lock()
+	wb.closed = true
+	wb.mu.Unlock()
+
+	close(wb.stop)
+	<-wb.done
+}
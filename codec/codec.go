@@ -0,0 +1,81 @@
+// Package codec turns cache values into bytes for byte-oriented backends
+package codec
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec - encodes values to bytes and back. Decode must not keep data,
+// backends reuse the buffer.
+type Codec[V any] interface {
+	Encode(value V) ([]byte, error)
+	Decode(data []byte) (V, error)
+}
+
+// Bytes - passes []byte values through, Decode copies
+type Bytes struct{}
+
+func (Bytes) Encode(value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func (Bytes) Decode(data []byte) ([]byte, error) {
+	return bytes.Clone(data), nil
+}
+
+// Gob - encoding/gob, handles most Go types without tags. Every value
+// carries its type description, so small values grow noticeably.
+type Gob[V any] struct{}
+
+func (Gob[V]) Encode(value V) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Gob[V]) Decode(data []byte) (V, error) {
+	var value V
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// JSON - encoding/json, only exported fields survive
+type JSON[V any] struct{}
+
+func (JSON[V]) Encode(value V) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSON[V]) Decode(data []byte) (V, error) {
+	var value V
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// Binary - uses V's own MarshalBinary and UnmarshalBinary
+type Binary[V encoding.BinaryMarshaler, PV binaryUnmarshaler[V]] struct{}
+
+type binaryUnmarshaler[V any] interface {
+	*V
+	encoding.BinaryUnmarshaler
+}
+
+// binary codec for V, e.g. NewBinary[time.Time]()
+func NewBinary[V encoding.BinaryMarshaler, PV binaryUnmarshaler[V]]() Binary[V, PV] {
+	return Binary[V, PV]{}
+}
+
+func (Binary[V, PV]) Encode(value V) ([]byte, error) {
+	return value.MarshalBinary()
+}
+
+func (Binary[V, PV]) Decode(data []byte) (V, error) {
+	var value V
+	err := PV(&value).UnmarshalBinary(data)
+	return value, err
+}
@@ -0,0 +1,67 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type User struct {
+	ID   int
+	Name string
+}
+
+func roundTrip[V any](t *testing.T, c Codec[V], value V, equal func(a, b V) bool) {
+	t.Helper()
+
+	data, err := c.Encode(value)
+	if err != nil {
+		t.Fatalf("Encode(%v) failed: %v", value, err)
+	}
+	got, err := c.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode(%v) failed: %v", value, err)
+	}
+	if !equal(got, value) {
+		t.Errorf("Expected %v after round trip, got %v", value, got)
+	}
+}
+
+func eq[V comparable](a, b V) bool {
+	return a == b
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	roundTrip(t, Codec[int](Gob[int]{}), 100, eq[int])
+	roundTrip(t, Codec[string](Gob[string]{}), "value1", eq[string])
+	roundTrip(t, Codec[User](Gob[User]{}), User{ID: 1, Name: "John"}, eq[User])
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	roundTrip(t, Codec[int](JSON[int]{}), 100, eq[int])
+	roundTrip(t, Codec[string](JSON[string]{}), "value1", eq[string])
+	roundTrip(t, Codec[User](JSON[User]{}), User{ID: 1, Name: "John"}, eq[User])
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	now := time.Now()
+	roundTrip(t, Codec[time.Time](NewBinary[time.Time]()), now, time.Time.Equal)
+}
+
+func TestBytesCodec(t *testing.T) {
+	buf := []byte("value")
+	got, _ := Bytes{}.Decode(buf)
+	buf[0] = 'X'
+	if !bytes.Equal(got, []byte("value")) {
+		t.Error("Expected Decode to copy the buffer")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := (JSON[User]{}).Decode([]byte("{")); err == nil {
+		t.Error("Expected error for truncated JSON")
+	}
+	if _, err := (Gob[User]{}).Decode([]byte{1, 2, 3}); err == nil {
+		t.Error("Expected error for garbage gob")
+	}
+}
@@ -1,6 +1,7 @@
 package eviction
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -239,3 +240,39 @@ func TestPolicyCapacityValidation(t *testing.T) {
 		t.Errorf("Expected size 1 even with negative capacity, got %d", policy2.Size())
 	}
 }
+
+func TestOrderedKeys(t *testing.T) {
+	policies := map[string]struct {
+		policy   Policy[string]
+		expected []string
+	}{
+		"LRU":  {NewLRU[string](3), []string{"first", "third", "second"}},
+		"FIFO": {NewFIFO[string](3), []string{"third", "second", "first"}},
+		"LIFO": {NewLIFO[string](3), []string{"first", "second", "third"}},
+	}
+
+	for name, tc := range policies {
+		tc.policy.Access("first")
+		tc.policy.Access("second")
+		tc.policy.Access("third")
+		tc.policy.Access("first")
+
+		ordered, ok := tc.policy.(Ordered[string])
+		if !ok {
+			t.Fatalf("%s: expected policy to implement Ordered", name)
+		}
+
+		var keys []string
+		for key := range ordered.Keys() {
+			keys = append(keys, key)
+		}
+		if fmt.Sprint(keys) != fmt.Sprint(tc.expected) {
+			t.Errorf("%s: expected %v, got %v", name, tc.expected, keys)
+		}
+
+		// last yielded key is the next eviction
+		if evicted, _ := tc.policy.Evict(); evicted != keys[len(keys)-1] {
+			t.Errorf("%s: expected %s to be evicted next, got %s", name, keys[len(keys)-1], evicted)
+		}
+	}
+}
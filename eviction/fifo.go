@@ -2,6 +2,7 @@ package eviction
 
 import (
 	"container/list"
+	"iter"
 	"sync"
 )
 
@@ -109,3 +110,19 @@ func (p *fifoPolicy[K]) Size() int {
 
 	return len(p.items)
 }
+
+// keys from newest to oldest
+func (p *fifoPolicy[K]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		if p.threadSafe {
+			p.mu.RLock()
+			defer p.mu.RUnlock()
+		}
+
+		for elem := p.order.Back(); elem != nil; elem = elem.Prev() {
+			if !yield(elem.Value.(*evictionItem[K]).key) {
+				return
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package eviction
 
 import (
+	"iter"
 	"sync"
 )
 
@@ -21,6 +22,13 @@ type Policy[K comparable] interface {
 	Size() int
 }
 
+// Ordered - optional Policy extension, yields tracked keys from the one
+// evicted last to the one evicted next (most recent first for LRU).
+// Thread-safe policies hold their lock while iterating.
+type Ordered[K comparable] interface {
+	Keys() iter.Seq[K]
+}
+
 // shared item structure for all policies
 type evictionItem[K comparable] struct {
 	key K
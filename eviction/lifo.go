@@ -2,6 +2,7 @@ package eviction
 
 import (
 	"container/list"
+	"iter"
 	"sync"
 )
 
@@ -110,3 +111,19 @@ func (p *lifoPolicy[K]) Size() int {
 	}
 	return len(p.items)
 }
+
+// keys from oldest to newest
+func (p *lifoPolicy[K]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		if p.threadSafe {
+			p.mu.RLock()
+			defer p.mu.RUnlock()
+		}
+
+		for elem := p.order.Front(); elem != nil; elem = elem.Next() {
+			if !yield(elem.Value.(*evictionItem[K]).key) {
+				return
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package eviction
 
 import (
 	"container/list"
+	"iter"
 	"sync"
 )
 
@@ -117,3 +118,19 @@ func (p *lruPolicy[K]) Size() int {
 
 	return len(p.items)
 }
+
+// keys from most to least recently used
+func (p *lruPolicy[K]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		if p.threadSafe {
+			p.mu.RLock()
+			defer p.mu.RUnlock()
+		}
+
+		for elem := p.order.Front(); elem != nil; elem = elem.Next() {
+			if !yield(elem.Value.(*evictionItem[K]).key) {
+				return
+			}
+		}
+	}
+}
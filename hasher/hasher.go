@@ -0,0 +1,122 @@
+// Package hasher provides key hashing for sharding and indexing
+package hasher
+
+import (
+	"hash/maphash"
+)
+
+// Hasher - hashes keys, equal keys always hash equally for the same Hasher
+type Hasher[K any] interface {
+	Hash(key K) uint64
+}
+
+// Func - plain function as a Hasher, e.g. for struct keys
+type Func[K any] func(key K) uint64
+
+func (f Func[K]) Hash(key K) uint64 {
+	return f(key)
+}
+
+// Integer - signed and unsigned integer kinds
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// String - hashes string keys
+type String[K ~string] struct {
+	seed maphash.Seed
+}
+
+// randomly seeded string hasher
+func NewString[K ~string]() String[K] {
+	return String[K]{seed: maphash.MakeSeed()}
+}
+
+func (h String[K]) Hash(key K) uint64 {
+	return maphash.String(h.seed, string(key))
+}
+
+// Bytes - hashes byte slice keys, for indexes that aren't Go maps
+type Bytes[K ~[]byte] struct {
+	seed maphash.Seed
+}
+
+// randomly seeded byte slice hasher
+func NewBytes[K ~[]byte]() Bytes[K] {
+	return Bytes[K]{seed: maphash.MakeSeed()}
+}
+
+func (h Bytes[K]) Hash(key K) uint64 {
+	return maphash.Bytes(h.seed, key)
+}
+
+// Int - hashes integer keys with a seeded mixer, no memory hashing
+type Int[K Integer] struct {
+	seed uint64
+}
+
+// randomly seeded integer hasher
+func NewInt[K Integer]() Int[K] {
+	return Int[K]{seed: maphash.String(maphash.MakeSeed(), "")}
+}
+
+func (h Int[K]) Hash(key K) uint64 {
+	// splitmix64 finalizer
+	x := uint64(key) ^ h.seed
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Comparable - hashes any comparable key, including structs and arrays
+type Comparable[K comparable] struct {
+	seed maphash.Seed
+}
+
+// randomly seeded hasher for any comparable key
+func NewComparable[K comparable]() Comparable[K] {
+	return Comparable[K]{seed: maphash.MakeSeed()}
+}
+
+func (h Comparable[K]) Hash(key K) uint64 {
+	return maphash.Comparable(h.seed, key)
+}
+
+// Default - fastest built-in hasher for K: String for string, Int for the
+// predeclared integer types, Comparable otherwise
+func Default[K comparable]() Hasher[K] {
+	var h any
+	switch any(*new(K)).(type) {
+	case string:
+		h = NewString[string]()
+	case int:
+		h = NewInt[int]()
+	case int8:
+		h = NewInt[int8]()
+	case int16:
+		h = NewInt[int16]()
+	case int32:
+		h = NewInt[int32]()
+	case int64:
+		h = NewInt[int64]()
+	case uint:
+		h = NewInt[uint]()
+	case uint8:
+		h = NewInt[uint8]()
+	case uint16:
+		h = NewInt[uint16]()
+	case uint32:
+		h = NewInt[uint32]()
+	case uint64:
+		h = NewInt[uint64]()
+	case uintptr:
+		h = NewInt[uintptr]()
+	default:
+		return NewComparable[K]()
+	}
+	return h.(Hasher[K])
+}
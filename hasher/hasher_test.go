@@ -0,0 +1,63 @@
+package hasher
+
+import (
+	"testing"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestBuiltinHashers(t *testing.T) {
+	s := NewString[string]()
+	if s.Hash("key") != s.Hash("key") || s.Hash("key") == s.Hash("other") {
+		t.Error("Expected string hashes to be stable and distinct")
+	}
+
+	b := NewBytes[[]byte]()
+	if b.Hash([]byte("key")) != b.Hash([]byte("key")) {
+		t.Error("Expected byte hashes to be stable")
+	}
+
+	i := NewInt[int64]()
+	seen := make(map[uint64]bool)
+	for k := int64(0); k < 10000; k++ {
+		seen[i.Hash(k)] = true
+	}
+	if len(seen) != 10000 {
+		t.Errorf("Expected no collisions over 10000 ints, got %d distinct", len(seen))
+	}
+
+	// low bits must spread, shards pick by mask
+	buckets := make([]int, 16)
+	for k := int64(0); k < 16000; k++ {
+		buckets[i.Hash(k)&15]++
+	}
+	for n, count := range buckets {
+		if count < 800 || count > 1200 {
+			t.Errorf("Expected even spread, bucket %d got %d", n, count)
+		}
+	}
+
+	c := NewComparable[point]()
+	if c.Hash(point{1, 2}) != c.Hash(point{1, 2}) || c.Hash(point{1, 2}) == c.Hash(point{2, 1}) {
+		t.Error("Expected struct hashes to be stable and distinct")
+	}
+}
+
+func TestDefaultHasher(t *testing.T) {
+	if _, ok := Default[string]().(String[string]); !ok {
+		t.Error("Expected String hasher for string keys")
+	}
+	if _, ok := Default[uint32]().(Int[uint32]); !ok {
+		t.Error("Expected Int hasher for uint32 keys")
+	}
+	if _, ok := Default[point]().(Comparable[point]); !ok {
+		t.Error("Expected Comparable hasher for struct keys")
+	}
+
+	var f Hasher[point] = Func[point](func(p point) uint64 { return uint64(p.X) })
+	if f.Hash(point{7, 1}) != 7 {
+		t.Error("Expected Func to call through")
+	}
+}
@@ -0,0 +1,555 @@
+package storage
+
+import (
+	"caching-lib/codec"
+	"caching-lib/hasher"
+	"encoding/binary"
+	"iter"
+	"math"
+	"sync"
+	"time"
+)
+
+// Codec - turns values into bytes and back for byte-oriented backends
+type Codec[V any] = codec.Codec[V]
+
+// BytesCodec - stores []byte values as-is
+type BytesCodec = codec.Bytes
+
+// arena size when none is given
+const defaultArenaSize = 1 << 20
+
+// the arena is split into segments of size/arenaSegments bytes, at least
+// arenaMinSegment, and an entry has to fit in one
+const (
+	arenaSegments   = 16
+	arenaMinSegment = 4 << 10
+)
+
+// entry layout: header, then key and value bytes
+const (
+	offLength  = 0  // uint32, whole entry
+	offKeyLen  = 4  // uint32
+	offExpires = 8  // int64 unix nanos
+	offVersion = 16 // uint64
+	offFlags   = 24 // byte
+
+	entryHeaderSize = 25
+
+	flagHasTTL = 1 << 0
+	flagDead   = 1 << 1
+)
+
+// ArenaStats - arena usage
+type ArenaStats struct {
+	Entries      int
+	LiveBytes    int
+	DeadBytes    int
+	Capacity     int
+	Segments     int
+	Compactions  int64
+	Collisions   int
+	EncodeErrors int64
+	// entries larger than a segment, or no room left under 4GB
+	WriteErrors int64
+}
+
+// ArenaStorage - keeps entries in preallocated byte segments indexed by key
+// hash, so the GC sees no per-entry pointers. Values go through a codec and
+// every read decodes a fresh Item. Entries are appended to the active
+// segment; overwritten and deleted ones leave holes. When the active
+// segment fills up, writing moves on to an empty segment, or compacts the
+// one with the most garbage in place, or adds a segment, so a write never
+// copies more than one segment. The arena grows up to 4GB.
+type ArenaStorage[K ~string, V any] struct {
+	mu         sync.RWMutex
+	threadSafe bool
+
+	codec  Codec[V]
+	hasher hasher.Hasher[K]
+
+	segs    []arenaSegment
+	segSize int
+	active  int
+	// segments with nothing live, ready for reuse
+	free []int
+
+	// hash -> entry offset (segment * segSize + position), verified against the stored key
+	index map[uint64]uint32
+	// keys whose hash slot is held by a different key
+	overflow map[K]uint32
+
+	compactions  int64
+	encodeErrors int64
+	writeErrors  int64
+}
+
+type arenaSegment struct {
+	buf  []byte
+	tail int
+	dead int
+}
+
+// thread-safe arena of size bytes
+func NewArenaStorage[K ~string, V any](size int, codec Codec[V]) *ArenaStorage[K, V] {
+	return NewArenaStorageWithConfig[K, V](size, codec, true)
+}
+
+// arena with custom config
+func NewArenaStorageWithConfig[K ~string, V any](size int, codec Codec[V], threadSafe bool) *ArenaStorage[K, V] {
+	if size <= 0 {
+		size = defaultArenaSize
+	}
+	segSize := max(size/arenaSegments, arenaMinSegment)
+
+	s := &ArenaStorage[K, V]{
+		threadSafe: threadSafe,
+		codec:      codec,
+		hasher:     hasher.NewString[K](),
+		segs:       make([]arenaSegment, max(size/segSize, 1)),
+		segSize:    segSize,
+		index:      make(map[uint64]uint32),
+		overflow:   make(map[K]uint32),
+	}
+	for i := range s.segs {
+		s.segs[i].buf = make([]byte, segSize)
+		if i > 0 {
+			s.free = append(s.free, i)
+		}
+	}
+	return s
+}
+
+func (s *ArenaStorage[K, V]) Get(key K) (*Item[V], bool) {
+	if s.threadSafe {
+		s.mu.RLock()
+	}
+
+	off, exists := s.find(key)
+	if !exists {
+		s.runlock()
+		return nil, false
+	}
+
+	if s.expired(off) {
+		// need write lock for delete
+		if s.threadSafe {
+			s.mu.RUnlock()
+			s.mu.Lock()
+			defer s.mu.Unlock()
+		}
+		if off, exists := s.find(key); exists && s.expired(off) {
+			s.remove(key, off)
+		}
+		return nil, false
+	}
+
+	defer s.runlock()
+	return s.decode(off)
+}
+
+// no lazy expiry, caller checks IsExpired
+func (s *ArenaStorage[K, V]) Peek(key K) (*Item[V], bool) {
+	if s.threadSafe {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	off, exists := s.find(key)
+	if !exists {
+		return nil, false
+	}
+	return s.decode(off)
+}
+
+// a value that fails to encode or find room counts in EncodeErrors or
+// WriteErrors
+func (s *ArenaStorage[K, V]) Set(key K, item *Item[V]) {
+	value, err := s.codec.Encode(item.Value)
+
+	if s.threadSafe {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	if err != nil {
+		s.encodeErrors++
+		s.drop(key)
+		return
+	}
+
+	size := entryHeaderSize + len(key) + len(value)
+	off, ok := s.alloc(size)
+	if !ok {
+		s.writeErrors++
+		s.drop(key)
+		return
+	}
+
+	e := s.entry(off, size)
+	binary.LittleEndian.PutUint32(e[offLength:], uint32(size))
+	binary.LittleEndian.PutUint32(e[offKeyLen:], uint32(len(key)))
+	var flags byte
+	var expires int64
+	if item.HasTTL {
+		flags |= flagHasTTL
+		expires = item.ExpiresAt.UnixNano()
+	}
+	binary.LittleEndian.PutUint64(e[offExpires:], uint64(expires))
+	binary.LittleEndian.PutUint64(e[offVersion:], item.Version)
+	e[offFlags] = flags
+	copy(e[entryHeaderSize:], key)
+	copy(e[entryHeaderSize+len(key):], value)
+
+	// looked up after alloc, compaction may have moved it
+	if old, exists := s.find(key); exists {
+		s.remove(key, old)
+	}
+
+	h := s.hasher.Hash(key)
+	if held, taken := s.index[h]; taken && string(s.keyBytes(held)) != string(key) {
+		s.overflow[key] = off
+	} else {
+		s.index[h] = off
+	}
+}
+
+func (s *ArenaStorage[K, V]) Delete(key K) bool {
+	if s.threadSafe {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	return s.drop(key)
+}
+
+// removes key if present (assumes lock held)
+func (s *ArenaStorage[K, V]) drop(key K) bool {
+	off, exists := s.find(key)
+	if exists {
+		s.remove(key, off)
+	}
+	return exists
+}
+
+func (s *ArenaStorage[K, V]) Clear() {
+	if s.threadSafe {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	s.active = 0
+	s.free = s.free[:0]
+	for i := range s.segs {
+		s.segs[i].tail = 0
+		s.segs[i].dead = 0
+		if i > 0 {
+			s.free = append(s.free, i)
+		}
+	}
+	clear(s.index)
+	clear(s.overflow)
+}
+
+func (s *ArenaStorage[K, V]) Size() int {
+	if s.threadSafe {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	return len(s.index) + len(s.overflow)
+}
+
+func (s *ArenaStorage[K, V]) Keys() []K {
+	if s.threadSafe {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	keys := make([]K, 0, len(s.index)+len(s.overflow))
+	for off := range s.offsets() {
+		keys = append(keys, K(s.keyBytes(off)))
+	}
+	return keys
+}
+
+// read lock is held while iterating, items are decoded copies
+func (s *ArenaStorage[K, V]) All() iter.Seq2[K, *Item[V]] {
+	return func(yield func(K, *Item[V]) bool) {
+		if s.threadSafe {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+		}
+
+		for off := range s.offsets() {
+			item, ok := s.decode(off)
+			if !ok {
+				continue
+			}
+			if !yield(K(s.keyBytes(off)), item) {
+				return
+			}
+		}
+	}
+}
+
+// cleanup expired stuff, reads headers only
+func (s *ArenaStorage[K, V]) CleanupExpired() int {
+	if s.threadSafe {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	var expired []uint32
+	for off := range s.offsets() {
+		if s.expired(off) {
+			expired = append(expired, off)
+		}
+	}
+	for _, off := range expired {
+		s.remove(K(s.keyBytes(off)), off)
+	}
+	return len(expired)
+}
+
+// presizes the index for capacity keys, segments are sized in bytes up front
+func (s *ArenaStorage[K, V]) Reserve(capacity int) {
+	if s.threadSafe {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	// make new map if empty
+	if len(s.index) == 0 && capacity > 0 {
+		s.index = make(map[uint64]uint32, capacity)
+	}
+}
+
+// Stats - arena usage
+func (s *ArenaStorage[K, V]) Stats() ArenaStats {
+	if s.threadSafe {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	stats := ArenaStats{
+		Entries:      len(s.index) + len(s.overflow),
+		Capacity:     len(s.segs) * s.segSize,
+		Segments:     len(s.segs),
+		Compactions:  s.compactions,
+		Collisions:   len(s.overflow),
+		EncodeErrors: s.encodeErrors,
+		WriteErrors:  s.writeErrors,
+	}
+	for _, seg := range s.segs {
+		stats.LiveBytes += seg.tail - seg.dead
+		stats.DeadBytes += seg.dead
+	}
+	return stats
+}
+
+func (s *ArenaStorage[K, V]) runlock() {
+	if s.threadSafe {
+		s.mu.RUnlock()
+	}
+}
+
+// offset of key's entry, checking the stored key against collisions
+func (s *ArenaStorage[K, V]) find(key K) (uint32, bool) {
+	if off, exists := s.index[s.hasher.Hash(key)]; exists && string(s.keyBytes(off)) == string(key) {
+		return off, true
+	}
+	if len(s.overflow) == 0 {
+		return 0, false
+	}
+	off, exists := s.overflow[key]
+	return off, exists
+}
+
+// offsets of every live entry
+func (s *ArenaStorage[K, V]) offsets() iter.Seq[uint32] {
+	return func(yield func(uint32) bool) {
+		for _, off := range s.index {
+			if !yield(off) {
+				return
+			}
+		}
+		for _, off := range s.overflow {
+			if !yield(off) {
+				return
+			}
+		}
+	}
+}
+
+// unindexes the entry at off and marks it dead, freeing its segment once
+// nothing in it is live
+func (s *ArenaStorage[K, V]) remove(key K, off uint32) {
+	h := s.hasher.Hash(key)
+	if held, exists := s.index[h]; exists && held == off {
+		delete(s.index, h)
+	} else {
+		delete(s.overflow, key)
+	}
+
+	e := s.entry(off, entryHeaderSize)
+	e[offFlags] |= flagDead
+
+	i := int(off) / s.segSize
+	seg := &s.segs[i]
+	seg.dead += int(binary.LittleEndian.Uint32(e[offLength:]))
+	if i != s.active && seg.dead == seg.tail {
+		seg.tail = 0
+		seg.dead = 0
+		s.free = append(s.free, i)
+	}
+}
+
+// n bytes of the segment holding off
+func (s *ArenaStorage[K, V]) entry(off uint32, n int) []byte {
+	pos := int(off) % s.segSize
+	return s.segs[int(off)/s.segSize].buf[pos : pos+n]
+}
+
+// whole entry at off
+func (s *ArenaStorage[K, V]) entryAt(off uint32) []byte {
+	size := binary.LittleEndian.Uint32(s.entry(off, entryHeaderSize)[offLength:])
+	return s.entry(off, int(size))
+}
+
+// stored key, aliases the arena so copy before keeping it
+func (s *ArenaStorage[K, V]) keyBytes(off uint32) []byte {
+	e := s.entryAt(off)
+	keyLen := binary.LittleEndian.Uint32(e[offKeyLen:])
+	return e[entryHeaderSize : entryHeaderSize+keyLen]
+}
+
+func (s *ArenaStorage[K, V]) expired(off uint32) bool {
+	e := s.entry(off, entryHeaderSize)
+	if e[offFlags]&flagHasTTL == 0 {
+		return false
+	}
+	expires := int64(binary.LittleEndian.Uint64(e[offExpires:]))
+	return time.Now().UnixNano() > expires
+}
+
+func (s *ArenaStorage[K, V]) decode(off uint32) (*Item[V], bool) {
+	e := s.entryAt(off)
+	keyLen := binary.LittleEndian.Uint32(e[offKeyLen:])
+
+	value, err := s.codec.Decode(e[entryHeaderSize+keyLen:])
+	if err != nil {
+		return nil, false
+	}
+
+	item := &Item[V]{Value: value, Version: binary.LittleEndian.Uint64(e[offVersion:])}
+	if e[offFlags]&flagHasTTL != 0 {
+		item.HasTTL = true
+		item.ExpiresAt = time.Unix(0, int64(binary.LittleEndian.Uint64(e[offExpires:])))
+	}
+	return item, true
+}
+
+// offset of size free bytes in the active segment, moving to another one
+// when it's full
+func (s *ArenaStorage[K, V]) alloc(size int) (uint32, bool) {
+	if size > s.segSize {
+		return 0, false
+	}
+	if s.segs[s.active].tail+size > s.segSize && !s.advance(size) {
+		return 0, false
+	}
+
+	seg := &s.segs[s.active]
+	off := uint32(s.active*s.segSize + seg.tail)
+	seg.tail += size
+	return off, true
+}
+
+// makes a segment with room for size the active one: the old active one if
+// nothing in it is live, a free one, the dirtiest one compacted if at
+// least a quarter of it is garbage, or a new one. Compacts at most one
+// segment.
+func (s *ArenaStorage[K, V]) advance(size int) bool {
+	if seg := &s.segs[s.active]; seg.dead == seg.tail {
+		seg.tail = 0
+		seg.dead = 0
+		return true
+	}
+	if n := len(s.free); n > 0 {
+		s.active = s.free[n-1]
+		s.free = s.free[:n-1]
+		return true
+	}
+
+	victim := s.dirtiest()
+	if victim >= 0 && s.segs[victim].dead >= max(size, s.segSize/4) {
+		s.compact(victim)
+		return true
+	}
+	if s.grow() {
+		return true
+	}
+	// out of address space, take any hole big enough
+	if victim >= 0 && s.segs[victim].dead >= size {
+		s.compact(victim)
+		return true
+	}
+	return false
+}
+
+// segment with the most dead bytes, -1 if none has any
+func (s *ArenaStorage[K, V]) dirtiest() int {
+	victim := -1
+	for i, seg := range s.segs {
+		if seg.dead > 0 && (victim < 0 || seg.dead > s.segs[victim].dead) {
+			victim = i
+		}
+	}
+	return victim
+}
+
+// adds an empty segment and makes it active, offsets must stay under 4GB
+func (s *ArenaStorage[K, V]) grow() bool {
+	if (len(s.segs)+1)*s.segSize > math.MaxUint32 {
+		return false
+	}
+	s.segs = append(s.segs, arenaSegment{buf: make([]byte, s.segSize)})
+	s.active = len(s.segs) - 1
+	return true
+}
+
+// slides segment i's live entries to its front and makes it active
+func (s *ArenaStorage[K, V]) compact(i int) {
+	seg := &s.segs[i]
+	base := i * s.segSize
+	tail := 0
+
+	for pos := 0; pos < seg.tail; {
+		n := int(binary.LittleEndian.Uint32(seg.buf[pos+offLength:]))
+		if seg.buf[pos+offFlags]&flagDead == 0 {
+			if tail != pos {
+				copy(seg.buf[tail:], seg.buf[pos:pos+n])
+				s.move(uint32(base+pos), uint32(base+tail))
+			}
+			tail += n
+		}
+		pos += n
+	}
+
+	seg.tail = tail
+	seg.dead = 0
+	s.active = i
+	s.compactions++
+}
+
+// points the index at an entry's new offset
+func (s *ArenaStorage[K, V]) move(from, to uint32) {
+	key := K(s.keyBytes(to))
+	h := s.hasher.Hash(key)
+	if held, exists := s.index[h]; exists && held == from {
+		s.index[h] = to
+	} else {
+		s.overflow[key] = to
+	}
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"cmp"
+	"slices"
+)
+
+// min degree, nodes other than the root hold degree-1 .. 2*degree-1 keys
+const btreeDegree = 16
+
+const btreeMaxKeys = 2*btreeDegree - 1
+
+// B-tree keyed by ordered K
+type btree[K cmp.Ordered, V any] struct {
+	root *btreeNode[K, V]
+	size int
+}
+
+type btreeNode[K cmp.Ordered, V any] struct {
+	keys     []K
+	items    []*Item[V]
+	children []*btreeNode[K, V] // empty for leaves
+}
+
+func (n *btreeNode[K, V]) leaf() bool {
+	return len(n.children) == 0
+}
+
+// index of the first key >= k and whether it equals k
+func (n *btreeNode[K, V]) find(k K) (int, bool) {
+	return slices.BinarySearch(n.keys, k)
+}
+
+func (t *btree[K, V]) get(k K) (*Item[V], bool) {
+	for n := t.root; n != nil; {
+		i, found := n.find(k)
+		if found {
+			return n.items[i], true
+		}
+		if n.leaf() {
+			return nil, false
+		}
+		n = n.children[i]
+	}
+	return nil, false
+}
+
+// inserts or replaces, returns the replaced item
+func (t *btree[K, V]) set(k K, item *Item[V]) (*Item[V], bool) {
+	if t.root == nil {
+		t.root = &btreeNode[K, V]{keys: []K{k}, items: []*Item[V]{item}}
+		t.size++
+		return nil, false
+	}
+
+	if len(t.root.keys) == btreeMaxKeys {
+		old := t.root
+		t.root = &btreeNode[K, V]{children: []*btreeNode[K, V]{old}}
+		t.root.splitChild(0)
+	}
+
+	old, replaced := t.root.insertNonFull(k, item)
+	if !replaced {
+		t.size++
+	}
+	return old, replaced
+}
+
+// splits the full child i around its median
+func (n *btreeNode[K, V]) splitChild(i int) {
+	child := n.children[i]
+	mid := btreeDegree - 1
+
+	right := &btreeNode[K, V]{
+		keys:  slices.Clone(child.keys[mid+1:]),
+		items: slices.Clone(child.items[mid+1:]),
+	}
+	if !child.leaf() {
+		right.children = slices.Clone(child.children[mid+1:])
+		clear(child.children[mid+1:])
+		child.children = child.children[:mid+1]
+	}
+
+	midKey, midItem := child.keys[mid], child.items[mid]
+	clear(child.items[mid:])
+	child.keys = child.keys[:mid]
+	child.items = child.items[:mid]
+
+	n.keys = slices.Insert(n.keys, i, midKey)
+	n.items = slices.Insert(n.items, i, midItem)
+	n.children = slices.Insert(n.children, i+1, right)
+}
+
+func (n *btreeNode[K, V]) insertNonFull(k K, item *Item[V]) (*Item[V], bool) {
+	for {
+		i, found := n.find(k)
+		if found {
+			old := n.items[i]
+			n.items[i] = item
+			return old, true
+		}
+
+		if n.leaf() {
+			n.keys = slices.Insert(n.keys, i, k)
+			n.items = slices.Insert(n.items, i, item)
+			return nil, false
+		}
+
+		if len(n.children[i].keys) == btreeMaxKeys {
+			n.splitChild(i)
+			switch {
+			case k == n.keys[i]:
+				old := n.items[i]
+				n.items[i] = item
+				return old, true
+			case k > n.keys[i]:
+				i++
+			}
+		}
+		n = n.children[i]
+	}
+}
+
+func (t *btree[K, V]) delete(k K) (*Item[V], bool) {
+	if t.root == nil {
+		return nil, false
+	}
+
+	item, removed := t.root.remove(k)
+	if len(t.root.keys) == 0 {
+		if t.root.leaf() {
+			t.root = nil
+		} else {
+			t.root = t.root.children[0]
+		}
+	}
+	if removed {
+		t.size--
+	}
+	return item, removed
+}
+
+// removes k from the subtree, n holds at least degree keys unless it's the root
+func (n *btreeNode[K, V]) remove(k K) (*Item[V], bool) {
+	i, found := n.find(k)
+
+	if n.leaf() {
+		if !found {
+			return nil, false
+		}
+		item := n.items[i]
+		n.keys = slices.Delete(n.keys, i, i+1)
+		n.items = slices.Delete(n.items, i, i+1)
+		return item, true
+	}
+
+	if found {
+		item := n.items[i]
+		switch {
+		case len(n.children[i].keys) >= btreeDegree:
+			// swap in the predecessor
+			pk, pi := n.children[i].max()
+			n.keys[i], n.items[i] = pk, pi
+			n.children[i].remove(pk)
+		case len(n.children[i+1].keys) >= btreeDegree:
+			// swap in the successor
+			sk, si := n.children[i+1].min()
+			n.keys[i], n.items[i] = sk, si
+			n.children[i+1].remove(sk)
+		default:
+			n.merge(i)
+			n.children[i].remove(k)
+		}
+		return item, true
+	}
+
+	if len(n.children[i].keys) < btreeDegree {
+		i = n.fill(i)
+	}
+	return n.children[i].remove(k)
+}
+
+func (n *btreeNode[K, V]) min() (K, *Item[V]) {
+	for !n.leaf() {
+		n = n.children[0]
+	}
+	return n.keys[0], n.items[0]
+}
+
+func (n *btreeNode[K, V]) max() (K, *Item[V]) {
+	for !n.leaf() {
+		n = n.children[len(n.children)-1]
+	}
+	last := len(n.keys) - 1
+	return n.keys[last], n.items[last]
+}
+
+// tops child i up to degree keys, returns the child's index afterwards
+func (n *btreeNode[K, V]) fill(i int) int {
+	switch {
+	case i > 0 && len(n.children[i-1].keys) >= btreeDegree:
+		n.borrowLeft(i)
+	case i < len(n.children)-1 && len(n.children[i+1].keys) >= btreeDegree:
+		n.borrowRight(i)
+	case i < len(n.children)-1:
+		n.merge(i)
+	default:
+		n.merge(i - 1)
+		i--
+	}
+	return i
+}
+
+// moves a key from the left sibling through n into child i
+func (n *btreeNode[K, V]) borrowLeft(i int) {
+	child, left := n.children[i], n.children[i-1]
+	last := len(left.keys) - 1
+
+	child.keys = slices.Insert(child.keys, 0, n.keys[i-1])
+	child.items = slices.Insert(child.items, 0, n.items[i-1])
+	if !left.leaf() {
+		child.children = slices.Insert(child.children, 0, left.children[last+1])
+		left.children = slices.Delete(left.children, last+1, last+2)
+	}
+
+	n.keys[i-1], n.items[i-1] = left.keys[last], left.items[last]
+	left.keys = slices.Delete(left.keys, last, last+1)
+	left.items = slices.Delete(left.items, last, last+1)
+}
+
+// moves a key from the right sibling through n into child i
+func (n *btreeNode[K, V]) borrowRight(i int) {
+	child, right := n.children[i], n.children[i+1]
+
+	child.keys = append(child.keys, n.keys[i])
+	child.items = append(child.items, n.items[i])
+	if !right.leaf() {
+		child.children = append(child.children, right.children[0])
+		right.children = slices.Delete(right.children, 0, 1)
+	}
+
+	n.keys[i], n.items[i] = right.keys[0], right.items[0]
+	right.keys = slices.Delete(right.keys, 0, 1)
+	right.items = slices.Delete(right.items, 0, 1)
+}
+
+// folds key i and child i+1 into child i
+func (n *btreeNode[K, V]) merge(i int) {
+	left, right := n.children[i], n.children[i+1]
+
+	left.keys = append(append(left.keys, n.keys[i]), right.keys...)
+	left.items = append(append(left.items, n.items[i]), right.items...)
+	left.children = append(left.children, right.children...)
+
+	n.keys = slices.Delete(n.keys, i, i+1)
+	n.items = slices.Delete(n.items, i, i+1)
+	n.children = slices.Delete(n.children, i+1, i+2)
+}
+
+// in-order walk over keys >= from (all keys unless bounded), false once yield stops
+func (n *btreeNode[K, V]) ascend(from K, bounded bool, yield func(K, *Item[V]) bool) bool {
+	i := 0
+	if bounded {
+		i, _ = n.find(from)
+	}
+
+	for ; i < len(n.keys); i++ {
+		if !n.leaf() && !n.children[i].ascend(from, bounded, yield) {
+			return false
+		}
+		if !yield(n.keys[i], n.items[i]) {
+			return false
+		}
+	}
+	if !n.leaf() {
+		return n.children[len(n.keys)].ascend(from, bounded, yield)
+	}
+	return true
+}
+
+func (t *btree[K, V]) ascend(from K, bounded bool, yield func(K, *Item[V]) bool) {
+	if t.root != nil {
+		t.root.ascend(from, bounded, yield)
+	}
+}
@@ -0,0 +1,364 @@
+package storage
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"iter"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Compression - algorithm recorded in the first byte of every stored value
+type Compression byte
+
+const (
+	// CompressionNone - stored as-is, below the threshold or not worth it
+	CompressionNone Compression = iota
+	// CompressionFlate - compress/flate
+	CompressionFlate
+	// CompressionGzip - compress/gzip, flate plus a checksum
+	CompressionGzip
+)
+
+// values smaller than this are stored raw by default
+const defaultCompressThreshold = 512
+
+var (
+	errUnknownCompression = errors.New("storage: unknown compression")
+	errLengthMismatch     = errors.New("storage: decompressed length mismatch")
+)
+
+// CompressionStats - size of the values currently stored, before and after
+// compression
+type CompressionStats struct {
+	RawBytes           int64
+	StoredBytes        int64
+	CompressedValues   int64
+	UncompressedValues int64
+}
+
+// CompressedStorage - encodes values with a codec and compresses those above
+// a threshold before handing them to a byte-oriented storage. Compressed
+// values carry their raw length after the header byte. Reads decode a fresh
+// Item.
+type CompressedStorage[K comparable, V any] struct {
+	inner       Storage[K, []byte]
+	codec       Codec[V]
+	compression Compression
+	level       int
+	threshold   int
+
+	writers sync.Pool
+
+	// serializes writes so replaced and removed values are accounted once
+	mu           sync.Mutex
+	rawBytes     int64
+	storedBytes  int64
+	compressed   int64
+	uncompressed int64
+}
+
+// flate-compressed values of 512 bytes or more
+func NewCompressedStorage[K comparable, V any](inner Storage[K, []byte], codec Codec[V]) *CompressedStorage[K, V] {
+	return NewCompressedStorageWithConfig(inner, codec, CompressionFlate, flate.DefaultCompression, defaultCompressThreshold)
+}
+
+// compressed storage with custom algorithm, level and threshold
+func NewCompressedStorageWithConfig[K comparable, V any](inner Storage[K, []byte], codec Codec[V], compression Compression, level, threshold int) *CompressedStorage[K, V] {
+	if compression != CompressionGzip {
+		compression = CompressionFlate
+	}
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		level = flate.DefaultCompression
+	}
+	if threshold < 0 {
+		threshold = defaultCompressThreshold
+	}
+
+	return &CompressedStorage[K, V]{
+		inner:       inner,
+		codec:       codec,
+		compression: compression,
+		level:       level,
+		threshold:   threshold,
+	}
+}
+
+type compressWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+func (s *CompressedStorage[K, V]) writer(w io.Writer) compressWriter {
+	if cw, ok := s.writers.Get().(compressWriter); ok {
+		cw.Reset(w)
+		return cw
+	}
+	// levels are validated up front, so these can't fail
+	if s.compression == CompressionGzip {
+		gw, _ := gzip.NewWriterLevel(w, s.level)
+		return gw
+	}
+	fw, _ := flate.NewWriter(w, s.level)
+	return fw
+}
+
+// header byte plus the raw value, or header byte, raw length and the
+// compressed value
+func (s *CompressedStorage[K, V]) encode(value V) ([]byte, error) {
+	raw, err := s.codec.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) >= s.threshold {
+		var buf bytes.Buffer
+		buf.WriteByte(byte(s.compression))
+		buf.Write(binary.AppendUvarint(nil, uint64(len(raw))))
+		cw := s.writer(&buf)
+		_, err := cw.Write(raw)
+		if err == nil {
+			err = cw.Close()
+		}
+		s.writers.Put(cw)
+
+		// keep it raw when compression doesn't pay off
+		if err == nil && buf.Len() < len(raw)+1 {
+			return buf.Bytes(), nil
+		}
+	}
+
+	out := make([]byte, 1+len(raw))
+	out[0] = byte(CompressionNone)
+	copy(out[1:], raw)
+	return out, nil
+}
+
+// adds a stored value to the stats, sign -1 takes it out
+func (s *CompressedStorage[K, V]) account(data []byte, sign int64) {
+	if len(data) == 0 {
+		return
+	}
+
+	raw := int64(len(data) - 1)
+	counter := &s.uncompressed
+	if Compression(data[0]) != CompressionNone {
+		n, _ := binary.Uvarint(data[1:])
+		raw = int64(n)
+		counter = &s.compressed
+	}
+	atomic.AddInt64(&s.rawBytes, sign*raw)
+	atomic.AddInt64(&s.storedBytes, sign*int64(len(data)))
+	atomic.AddInt64(counter, sign)
+}
+
+func (s *CompressedStorage[K, V]) decode(data []byte) (V, error) {
+	var zero V
+	if len(data) == 0 {
+		return zero, errUnknownCompression
+	}
+
+	payload := data[1:]
+	var size uint64
+	if Compression(data[0]) != CompressionNone {
+		var n int
+		size, n = binary.Uvarint(payload)
+		if n <= 0 {
+			return zero, errUnknownCompression
+		}
+		payload = payload[n:]
+	}
+
+	switch Compression(data[0]) {
+	case CompressionNone:
+		return s.codec.Decode(payload)
+	case CompressionFlate:
+		r := flate.NewReader(bytes.NewReader(payload))
+		defer r.Close()
+		return s.decodeFrom(r, size)
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return zero, err
+		}
+		defer r.Close()
+		return s.decodeFrom(r, size)
+	default:
+		return zero, errUnknownCompression
+	}
+}
+
+// reads at most size+1 bytes, so a corrupt stream can't inflate past the
+// length stored with it
+func (s *CompressedStorage[K, V]) decodeFrom(r io.Reader, size uint64) (V, error) {
+	var zero V
+	raw, err := io.ReadAll(io.LimitReader(r, int64(min(size, math.MaxInt64-1))+1))
+	if err != nil {
+		return zero, err
+	}
+	if uint64(len(raw)) != size {
+		return zero, errLengthMismatch
+	}
+	return s.codec.Decode(raw)
+}
+
+// decoded copy of an inner item, false if it can't be decoded
+func (s *CompressedStorage[K, V]) unwrap(inner *Item[[]byte]) (*Item[V], bool) {
+	value, err := s.decode(inner.Value)
+	if err != nil {
+		return nil, false
+	}
+	return &Item[V]{
+		Value:     value,
+		ExpiresAt: inner.ExpiresAt,
+		HasTTL:    inner.HasTTL,
+		Version:   inner.Version,
+	}, true
+}
+
+// expired values are removed here rather than by the inner Get, so the
+// stats see them go
+func (s *CompressedStorage[K, V]) Get(key K) (*Item[V], bool) {
+	inner, exists := s.inner.Peek(key)
+	if !exists {
+		return nil, false
+	}
+	if inner.IsExpired() {
+		s.mu.Lock()
+		if inner, exists := s.inner.Peek(key); exists && inner.IsExpired() {
+			s.removeLocked(key, inner)
+		}
+		s.mu.Unlock()
+		return nil, false
+	}
+	return s.unwrap(inner)
+}
+
+func (s *CompressedStorage[K, V]) Peek(key K) (*Item[V], bool) {
+	inner, exists := s.inner.Peek(key)
+	if !exists {
+		return nil, false
+	}
+	return s.unwrap(inner)
+}
+
+func (s *CompressedStorage[K, V]) Set(key K, item *Item[V]) {
+	data, err := s.encode(item.Value)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, exists := s.inner.Peek(key); exists {
+		s.removeLocked(key, old)
+	}
+	if err != nil {
+		return
+	}
+
+	s.inner.Set(key, &Item[[]byte]{
+		Value:     data,
+		ExpiresAt: item.ExpiresAt,
+		HasTTL:    item.HasTTL,
+		Version:   item.Version,
+	})
+	// the inner storage may have dropped it
+	if _, exists := s.inner.Peek(key); exists {
+		s.account(data, 1)
+	}
+}
+
+func (s *CompressedStorage[K, V]) Delete(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, exists := s.inner.Peek(key)
+	if !exists {
+		return false
+	}
+	return s.removeLocked(key, old)
+}
+
+// deletes key and takes old out of the stats, before the inner storage can
+// recycle it (assumes lock held)
+func (s *CompressedStorage[K, V]) removeLocked(key K, old *Item[[]byte]) bool {
+	data := old.Value
+	if !s.inner.Delete(key) {
+		return false
+	}
+	s.account(data, -1)
+	return true
+}
+
+func (s *CompressedStorage[K, V]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inner.Clear()
+	atomic.StoreInt64(&s.rawBytes, 0)
+	atomic.StoreInt64(&s.storedBytes, 0)
+	atomic.StoreInt64(&s.compressed, 0)
+	atomic.StoreInt64(&s.uncompressed, 0)
+}
+
+func (s *CompressedStorage[K, V]) Size() int {
+	return s.inner.Size()
+}
+
+func (s *CompressedStorage[K, V]) Keys() []K {
+	return s.inner.Keys()
+}
+
+// decodes while the inner storage iterates
+func (s *CompressedStorage[K, V]) All() iter.Seq2[K, *Item[V]] {
+	return func(yield func(K, *Item[V]) bool) {
+		for key, inner := range s.inner.All() {
+			item, ok := s.unwrap(inner)
+			if !ok {
+				continue
+			}
+			if !yield(key, item) {
+				return
+			}
+		}
+	}
+}
+
+// removes expired values itself so the stats see them go
+func (s *CompressedStorage[K, V]) CleanupExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []K
+	for key, inner := range s.inner.All() {
+		if inner.IsExpired() {
+			expired = append(expired, key)
+		}
+	}
+
+	var removed int
+	for _, key := range expired {
+		if inner, exists := s.inner.Peek(key); exists && inner.IsExpired() && s.removeLocked(key, inner) {
+			removed++
+		}
+	}
+	return removed
+}
+
+func (s *CompressedStorage[K, V]) Reserve(capacity int) {
+	s.inner.Reserve(capacity)
+}
+
+// Stats - footprint of the values currently stored
+func (s *CompressedStorage[K, V]) Stats() CompressionStats {
+	return CompressionStats{
+		RawBytes:           atomic.LoadInt64(&s.rawBytes),
+		StoredBytes:        atomic.LoadInt64(&s.storedBytes),
+		CompressedValues:   atomic.LoadInt64(&s.compressed),
+		UncompressedValues: atomic.LoadInt64(&s.uncompressed),
+	}
+}
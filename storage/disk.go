@@ -0,0 +1,800 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCorruptSegment - a segment file has a bad header
+var ErrCorruptSegment = errors.New("storage: corrupt segment")
+
+var errBadChecksum = errors.New("storage: record checksum mismatch")
+
+// segment file header: magic, format version, flags
+const (
+	segmentMagic      = "CSEG"
+	segmentVersion    = 1
+	segmentHeaderSize = 6
+
+	// everything older than a compacted segment is stale
+	segmentCompacted = 1 << 0
+)
+
+// record layout: crc of the rest, key length, value length, flags, expiry, version, key, value
+const (
+	recCRC     = 0
+	recKeyLen  = 4
+	recValLen  = 8
+	recFlags   = 12
+	recExpires = 13
+	recVersion = 21
+
+	recordHeaderSize = 29
+
+	recTombstone = 1 << 0
+	recHasTTL    = 1 << 1
+)
+
+// DiskOptions - tuning for DiskStorage, zero values pick defaults
+type DiskOptions struct {
+	// SegmentSize - active segment is sealed past this many bytes (64MB)
+	SegmentSize int64
+	// SyncWrites - fsync after every write, slower but loses nothing on power loss
+	SyncWrites bool
+	// CompactInterval - how often to check for garbage (1m), negative disables
+	CompactInterval time.Duration
+	// CompactRatio - dead share of sealed bytes that triggers compaction (0.5)
+	CompactRatio float64
+}
+
+// DiskStats - segment log usage
+type DiskStats struct {
+	Segments       int
+	Entries        int
+	LiveBytes      int64
+	DeadBytes      int64
+	Compactions    int64
+	WriteErrors    int64
+	TruncatedBytes int64
+}
+
+// DiskStorage - append-only segment log on disk with an in-memory index of
+// record locations. Deletes write tombstones, every record carries a CRC, and
+// a torn tail left by a crash is cut off on open. Sealed segments are
+// compacted in the background once enough of them is garbage. Values go
+// through a codec and every read decodes a fresh Item.
+type DiskStorage[K ~string, V any] struct {
+	mu    sync.RWMutex
+	dir   string
+	codec Codec[V]
+	opts  DiskOptions
+
+	segments map[uint64]*segment
+	active   *segment
+	index    map[K]diskLoc
+	// bumped by Clear so in-flight compactions give up
+	gen uint64
+
+	// one compaction at a time
+	compacting sync.Mutex
+
+	compactions    int64
+	writeErrors    int64
+	truncatedBytes int64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type segment struct {
+	id   uint64
+	file *os.File
+	size int64
+	dead int64
+}
+
+type diskLoc struct {
+	seg     uint64
+	off     int64
+	size    uint32
+	expires int64 // unix nanos, 0 without TTL
+}
+
+func (l diskLoc) expired() bool {
+	return l.expires != 0 && time.Now().UnixNano() > l.expires
+}
+
+// opens or creates the log in dir, replaying existing segments
+func OpenDiskStorage[K ~string, V any](dir string, codec Codec[V], opts DiskOptions) (*DiskStorage[K, V], error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = 64 << 20
+	}
+	if opts.CompactInterval == 0 {
+		opts.CompactInterval = time.Minute
+	}
+	if opts.CompactRatio <= 0 || opts.CompactRatio > 1 {
+		opts.CompactRatio = 0.5
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &DiskStorage[K, V]{
+		dir:      dir,
+		codec:    codec,
+		opts:     opts,
+		segments: make(map[uint64]*segment),
+		index:    make(map[K]diskLoc),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		s.closeFiles()
+		return nil, err
+	}
+
+	if opts.CompactInterval > 0 {
+		go s.runCompaction()
+	} else {
+		close(s.done)
+	}
+	return s, nil
+}
+
+func segmentName(id uint64) string {
+	return fmt.Sprintf("%016x.seg", id)
+}
+
+func (s *DiskStorage[K, V]) segmentPath(id uint64) string {
+	return filepath.Join(s.dir, segmentName(id))
+}
+
+// replays segments oldest first, then starts a fresh active segment
+func (s *DiskStorage[K, V]) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	var ids []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			// unfinished compaction
+			os.Remove(filepath.Join(s.dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, ".seg") {
+			continue
+		}
+		if id, err := strconv.ParseUint(strings.TrimSuffix(name, ".seg"), 16, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	var files []*os.File
+	var flags []byte
+	for _, id := range ids {
+		f, flag, err := openSegment(s.segmentPath(id))
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return fmt.Errorf("%w: %s: %v", ErrCorruptSegment, segmentName(id), err)
+		}
+		files = append(files, f)
+		flags = append(flags, flag)
+	}
+
+	// a compacted segment replaces everything before it
+	start := 0
+	for i := len(ids) - 1; i >= 0; i-- {
+		if flags[i]&segmentCompacted != 0 {
+			start = i
+			break
+		}
+	}
+	for i := 0; i < start; i++ {
+		files[i].Close()
+		os.Remove(s.segmentPath(ids[i]))
+	}
+
+	next := uint64(1)
+	for i := start; i < len(ids); i++ {
+		seg := &segment{id: ids[i], file: files[i]}
+		s.segments[seg.id] = seg
+		if err := s.replay(seg); err != nil {
+			return err
+		}
+		next = seg.id + 1
+	}
+
+	return s.newActive(next)
+}
+
+func openSegment(path string) (*os.File, byte, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	header := make([]byte, segmentHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:4]) != segmentMagic {
+		f.Close()
+		return nil, 0, errors.New("bad header")
+	}
+	if header[4] != segmentVersion {
+		f.Close()
+		return nil, 0, fmt.Errorf("unsupported format version %d", header[4])
+	}
+	return f, header[5], nil
+}
+
+// applies a segment's records to the index, cutting off a torn tail
+func (s *DiskStorage[K, V]) replay(seg *segment) error {
+	info, err := seg.file.Stat()
+	if err != nil {
+		return err
+	}
+	fileSize := info.Size()
+
+	r := bufio.NewReader(io.NewSectionReader(seg.file, segmentHeaderSize, fileSize-segmentHeaderSize))
+	off := int64(segmentHeaderSize)
+	header := make([]byte, recordHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			break
+		}
+		keyLen := int64(binary.LittleEndian.Uint32(header[recKeyLen:]))
+		valLen := int64(binary.LittleEndian.Uint32(header[recValLen:]))
+		size := recordHeaderSize + keyLen + valLen
+		if off+size > fileSize {
+			break
+		}
+
+		rec := make([]byte, size)
+		copy(rec, header)
+		if _, err := io.ReadFull(r, rec[recordHeaderSize:]); err != nil {
+			break
+		}
+		if checkRecord(rec) != nil {
+			break
+		}
+
+		s.apply(seg, rec, off)
+		off += size
+	}
+
+	if off < fileSize {
+		if err := seg.file.Truncate(off); err != nil {
+			return err
+		}
+		s.truncatedBytes += fileSize - off
+	}
+	seg.size = off
+	return nil
+}
+
+// replays one verified record into the index
+func (s *DiskStorage[K, V]) apply(seg *segment, rec []byte, off int64) {
+	keyLen := binary.LittleEndian.Uint32(rec[recKeyLen:])
+	key := K(rec[recordHeaderSize : recordHeaderSize+keyLen])
+	flags := rec[recFlags]
+
+	if old, exists := s.index[key]; exists {
+		s.segments[old.seg].dead += int64(old.size)
+		delete(s.index, key)
+	}
+
+	loc := diskLoc{seg: seg.id, off: off, size: uint32(len(rec))}
+	if flags&recHasTTL != 0 {
+		loc.expires = int64(binary.LittleEndian.Uint64(rec[recExpires:]))
+	}
+	if flags&recTombstone != 0 || loc.expired() {
+		seg.dead += int64(len(rec))
+		return
+	}
+	s.index[key] = loc
+}
+
+func checkRecord(rec []byte) error {
+	if crc32.ChecksumIEEE(rec[recKeyLen:]) != binary.LittleEndian.Uint32(rec[recCRC:]) {
+		return errBadChecksum
+	}
+	return nil
+}
+
+func encodeRecord(key string, value []byte, flags byte, expires int64, version uint64) []byte {
+	rec := make([]byte, recordHeaderSize+len(key)+len(value))
+	binary.LittleEndian.PutUint32(rec[recKeyLen:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(rec[recValLen:], uint32(len(value)))
+	rec[recFlags] = flags
+	binary.LittleEndian.PutUint64(rec[recExpires:], uint64(expires))
+	binary.LittleEndian.PutUint64(rec[recVersion:], version)
+	copy(rec[recordHeaderSize:], key)
+	copy(rec[recordHeaderSize+len(key):], value)
+	binary.LittleEndian.PutUint32(rec[recCRC:], crc32.ChecksumIEEE(rec[recKeyLen:]))
+	return rec
+}
+
+func createSegment(path string, flags byte) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(segmentMagic), segmentVersion, flags)
+	if _, err := f.Write(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// assumes lock held
+func (s *DiskStorage[K, V]) newActive(id uint64) error {
+	f, err := createSegment(s.segmentPath(id), 0)
+	if err != nil {
+		return err
+	}
+	s.active = &segment{id: id, file: f, size: segmentHeaderSize}
+	s.segments[id] = s.active
+	return nil
+}
+
+// appends rec to the active segment, sealing it when full (assumes lock held)
+func (s *DiskStorage[K, V]) appendLocked(rec []byte) (diskLoc, error) {
+	seg := s.active
+	_, err := seg.file.WriteAt(rec, seg.size)
+	if err == nil && s.opts.SyncWrites {
+		err = seg.file.Sync()
+	}
+	if err != nil {
+		// cut off whatever made it out so a restart can't replay it
+		seg.file.Truncate(seg.size)
+		return diskLoc{}, err
+	}
+
+	loc := diskLoc{seg: seg.id, off: seg.size, size: uint32(len(rec))}
+	seg.size += int64(len(rec))
+
+	if seg.size >= s.opts.SegmentSize {
+		if err := s.newActive(seg.id + 1); err != nil {
+			// keep appending to the oversized segment
+			s.active = seg
+		}
+	}
+	return loc, nil
+}
+
+// reads and verifies the record at loc
+func (s *DiskStorage[K, V]) readLocked(loc diskLoc) (*Item[V], bool) {
+	seg, exists := s.segments[loc.seg]
+	if !exists {
+		return nil, false
+	}
+
+	rec := make([]byte, loc.size)
+	if _, err := seg.file.ReadAt(rec, loc.off); err != nil || checkRecord(rec) != nil {
+		return nil, false
+	}
+
+	keyLen := binary.LittleEndian.Uint32(rec[recKeyLen:])
+	value, err := s.codec.Decode(rec[recordHeaderSize+keyLen:])
+	if err != nil {
+		return nil, false
+	}
+
+	item := &Item[V]{Value: value, Version: binary.LittleEndian.Uint64(rec[recVersion:])}
+	if loc.expires != 0 {
+		item.HasTTL = true
+		item.ExpiresAt = time.Unix(0, loc.expires)
+	}
+	return item, true
+}
+
+// forgets key without a tombstone, its record is expired anyway (assumes lock held)
+func (s *DiskStorage[K, V]) dropLocked(key K, loc diskLoc) {
+	delete(s.index, key)
+	if seg, exists := s.segments[loc.seg]; exists {
+		seg.dead += int64(loc.size)
+	}
+}
+
+func (s *DiskStorage[K, V]) Get(key K) (*Item[V], bool) {
+	s.mu.RLock()
+	loc, exists := s.index[key]
+	if !exists {
+		s.mu.RUnlock()
+		return nil, false
+	}
+
+	if loc.expired() {
+		// need write lock for delete
+		s.mu.RUnlock()
+		s.mu.Lock()
+		if loc, exists := s.index[key]; exists && loc.expired() {
+			s.dropLocked(key, loc)
+		}
+		s.mu.Unlock()
+		return nil, false
+	}
+
+	defer s.mu.RUnlock()
+	return s.readLocked(loc)
+}
+
+// no lazy expiry, caller checks IsExpired
+func (s *DiskStorage[K, V]) Peek(key K) (*Item[V], bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	loc, exists := s.index[key]
+	if !exists {
+		return nil, false
+	}
+	return s.readLocked(loc)
+}
+
+// write failures count in WriteErrors and leave a tombstone
+func (s *DiskStorage[K, V]) Set(key K, item *Item[V]) {
+	value, err := s.codec.Encode(item.Value)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.writeErrors++
+		s.forgetLocked(key)
+		return
+	}
+
+	var flags byte
+	var expires int64
+	if item.HasTTL {
+		flags |= recHasTTL
+		expires = item.ExpiresAt.UnixNano()
+	}
+
+	loc, err := s.appendLocked(encodeRecord(string(key), value, flags, expires, item.Version))
+	if err != nil {
+		s.writeErrors++
+		s.forgetLocked(key)
+		return
+	}
+	if old, existed := s.index[key]; existed {
+		s.dropLocked(key, old)
+	}
+	loc.expires = expires
+	s.index[key] = loc
+}
+
+// unindexes key after a failed write, with a tombstone so a restart doesn't
+// bring the old record back. If the log can't take that either, the old
+// record returns on the next open (assumes lock held)
+func (s *DiskStorage[K, V]) forgetLocked(key K) {
+	old, exists := s.index[key]
+	if !exists {
+		return
+	}
+	s.dropLocked(key, old)
+
+	loc, err := s.appendLocked(encodeRecord(string(key), nil, recTombstone, 0, 0))
+	if err != nil {
+		s.writeErrors++
+		return
+	}
+	s.segments[loc.seg].dead += int64(loc.size)
+}
+
+// false if the tombstone couldn't be written, the key then stays
+func (s *DiskStorage[K, V]) Delete(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, exists := s.index[key]
+	if !exists {
+		return false
+	}
+
+	loc, err := s.appendLocked(encodeRecord(string(key), nil, recTombstone, 0, 0))
+	if err != nil {
+		s.writeErrors++
+		return false
+	}
+	s.dropLocked(key, old)
+	// tombstones are garbage as soon as their segment gets compacted
+	s.segments[loc.seg].dead += int64(loc.size)
+	return true
+}
+
+// removes every segment and starts an empty log
+func (s *DiskStorage[K, V]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gen++
+	for id, seg := range s.segments {
+		seg.file.Close()
+		os.Remove(s.segmentPath(id))
+	}
+	next := s.active.id + 1
+	s.segments = make(map[uint64]*segment)
+	clear(s.index)
+
+	if err := s.newActive(next); err != nil {
+		s.writeErrors++
+	}
+}
+
+func (s *DiskStorage[K, V]) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.index)
+}
+
+func (s *DiskStorage[K, V]) Keys() []K {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]K, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// read lock is held while iterating, every item is read from disk
+func (s *DiskStorage[K, V]) All() iter.Seq2[K, *Item[V]] {
+	return func(yield func(K, *Item[V]) bool) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		for key, loc := range s.index {
+			item, ok := s.readLocked(loc)
+			if !ok {
+				continue
+			}
+			if !yield(key, item) {
+				return
+			}
+		}
+	}
+}
+
+// cleanup expired stuff from the index, compaction reclaims the bytes
+func (s *DiskStorage[K, V]) CleanupExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int
+	for key, loc := range s.index {
+		if loc.expired() {
+			s.dropLocked(key, loc)
+			removed++
+		}
+	}
+	return removed
+}
+
+// log grows on demand
+func (s *DiskStorage[K, V]) Reserve(capacity int) {}
+
+// Stats - segment log usage
+func (s *DiskStorage[K, V]) Stats() DiskStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := DiskStats{
+		Segments:       len(s.segments),
+		Entries:        len(s.index),
+		Compactions:    s.compactions,
+		WriteErrors:    s.writeErrors,
+		TruncatedBytes: s.truncatedBytes,
+	}
+	for _, seg := range s.segments {
+		stats.LiveBytes += seg.size - segmentHeaderSize - seg.dead
+		stats.DeadBytes += seg.dead
+	}
+	return stats
+}
+
+func (s *DiskStorage[K, V]) runCompaction() {
+	ticker := time.NewTicker(s.opts.CompactInterval)
+	defer ticker.Stop()
+	defer close(s.done)
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.needsCompaction() {
+				s.Compact()
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// dead share of sealed segments is past the ratio
+func (s *DiskStorage[K, V]) needsCompaction() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var size, dead int64
+	for id, seg := range s.segments {
+		if id < s.active.id {
+			size += seg.size - segmentHeaderSize
+			dead += seg.dead
+		}
+	}
+	return size > 0 && float64(dead) >= float64(size)*s.opts.CompactRatio
+}
+
+// Compact - rewrites live records of all sealed segments into one compacted
+// segment. Records are copied without the lock; writes meanwhile land in the
+// active segment and win over the copies.
+func (s *DiskStorage[K, V]) Compact() error {
+	s.compacting.Lock()
+	defer s.compacting.Unlock()
+
+	type move struct {
+		key K
+		loc diskLoc
+	}
+
+	s.mu.Lock()
+	gen := s.gen
+	var sealed []uint64
+	files := make(map[uint64]*os.File)
+	for id, seg := range s.segments {
+		if id < s.active.id {
+			sealed = append(sealed, id)
+			files[id] = seg.file
+		}
+	}
+	if len(sealed) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	slices.Sort(sealed)
+	target := sealed[len(sealed)-1]
+
+	var moves []move
+	for key, loc := range s.index {
+		if loc.seg < s.active.id && !loc.expired() {
+			moves = append(moves, move{key: key, loc: loc})
+		}
+	}
+	s.mu.Unlock()
+
+	tmpPath := s.segmentPath(target) + ".tmp"
+	f, err := createSegment(tmpPath, segmentCompacted)
+	if err != nil {
+		return err
+	}
+	abort := func(err error) error {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	moved := make(map[K]diskLoc, len(moves))
+	off := int64(segmentHeaderSize)
+	for _, m := range moves {
+		rec := make([]byte, m.loc.size)
+		if _, err := files[m.loc.seg].ReadAt(rec, m.loc.off); err != nil {
+			return abort(err)
+		}
+		if checkRecord(rec) != nil {
+			continue
+		}
+		if _, err := w.Write(rec); err != nil {
+			return abort(err)
+		}
+		moved[m.key] = diskLoc{seg: target, off: off, size: m.loc.size, expires: m.loc.expires}
+		off += int64(len(rec))
+	}
+	if err := w.Flush(); err != nil {
+		return abort(err)
+	}
+	if err := f.Sync(); err != nil {
+		return abort(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.gen != gen {
+		return abort(nil)
+	}
+	// the rename is the commit point, recovery ignores segments before target
+	if err := os.Rename(tmpPath, s.segmentPath(target)); err != nil {
+		return abort(err)
+	}
+	syncDir(s.dir)
+
+	compacted := &segment{id: target, file: f, size: off}
+	for _, id := range sealed {
+		s.segments[id].file.Close()
+		delete(s.segments, id)
+		if id != target {
+			os.Remove(s.segmentPath(id))
+		}
+	}
+	s.segments[target] = compacted
+
+	snapshot := make(map[K]diskLoc, len(moves))
+	for _, m := range moves {
+		snapshot[m.key] = m.loc
+	}
+	for key, loc := range s.index {
+		if !slices.Contains(sealed, loc.seg) {
+			continue
+		}
+		if newLoc, ok := moved[key]; ok && snapshot[key] == loc {
+			s.index[key] = newLoc
+			delete(moved, key)
+		} else {
+			// expired before the snapshot or its record was unreadable
+			delete(s.index, key)
+		}
+	}
+	// copies overwritten or deleted while we were copying
+	for _, loc := range moved {
+		compacted.dead += int64(loc.size)
+	}
+
+	s.compactions++
+	return nil
+}
+
+// makes renames durable, best effort
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// Close - stops compaction and closes segment files
+func (s *DiskStorage[K, V]) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+
+		s.compacting.Lock()
+		defer s.compacting.Unlock()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.active != nil {
+			err = s.active.file.Sync()
+		}
+		s.closeFiles()
+	})
+	return err
+}
+
+func (s *DiskStorage[K, V]) closeFiles() {
+	for _, seg := range s.segments {
+		seg.file.Close()
+	}
+}
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+)
+
+// ErrUnknownKey - no key with the requested ID
+var ErrUnknownKey = errors.New("storage: unknown encryption key")
+
+var errCiphertextTooShort = errors.New("storage: ciphertext too short")
+
+// KeyProvider - supplies AES keys (16, 24 or 32 bytes) by ID. New values
+// are sealed with the current key; older values name the key they need.
+type KeyProvider interface {
+	CurrentKey() (id uint32, key []byte, err error)
+	Key(id uint32) ([]byte, error)
+}
+
+// KeyRing - in-memory KeyProvider, safe for concurrent use
+type KeyRing struct {
+	mu      sync.RWMutex
+	keys    map[uint32][]byte
+	current uint32
+}
+
+// key ring starting with a single key, key is copied
+func NewKeyRing(id uint32, key []byte) *KeyRing {
+	return &KeyRing{
+		keys:    map[uint32][]byte{id: bytes.Clone(key)},
+		current: id,
+	}
+}
+
+// Rotate - adds a copy of key and seals new values with it, old keys still decrypt
+func (r *KeyRing) Rotate(id uint32, key []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[id] = bytes.Clone(key)
+	r.current = id
+}
+
+// Retire - forgets a key, values sealed with it become unreadable
+func (r *KeyRing) Retire(id uint32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id != r.current {
+		delete(r.keys, id)
+	}
+}
+
+func (r *KeyRing) CurrentKey() (uint32, []byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current, r.keys[r.current], nil
+}
+
+func (r *KeyRing) Key(id uint32) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, exists := r.keys[id]
+	if !exists {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// EncryptedStorage - encodes values with a codec and seals them with
+// AES-GCM before handing them to a byte-oriented storage, which only ever
+// sees key ID, nonce and ciphertext. The key ID and the storage key are
+// authenticated too, so a value copied to another key or relabelled with
+// another key ID fails to open. Values that fail to decrypt read as missing.
+type EncryptedStorage[K ~string, V any] struct {
+	inner Storage[K, []byte]
+	codec Codec[V]
+	keys  KeyProvider
+
+	// ciphers by key ID, rebuilt when the provider hands out different key bytes
+	mu    sync.RWMutex
+	aeads map[uint32]keyedAEAD
+}
+
+type keyedAEAD struct {
+	key  []byte
+	aead cipher.AEAD
+}
+
+// values sealed with keys from provider
+func NewEncryptedStorage[K ~string, V any](inner Storage[K, []byte], codec Codec[V], keys KeyProvider) *EncryptedStorage[K, V] {
+	return &EncryptedStorage[K, V]{
+		inner: inner,
+		codec: codec,
+		keys:  keys,
+		aeads: make(map[uint32]keyedAEAD),
+	}
+}
+
+func (s *EncryptedStorage[K, V]) aead(id uint32, key []byte) (cipher.AEAD, error) {
+	s.mu.RLock()
+	cached, exists := s.aeads[id]
+	s.mu.RUnlock()
+	if exists && bytes.Equal(cached.key, key) {
+		return cached.aead, nil
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("storage: key %d: %w", id, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.aeads[id] = keyedAEAD{key: bytes.Clone(key), aead: aead}
+	s.mu.Unlock()
+	return aead, nil
+}
+
+// authenticated with each value: the key ID header, then the storage key
+func additionalData[K ~string](header []byte, key K) []byte {
+	return append(header[:4:4], key...)
+}
+
+// key ID, nonce, then ciphertext with tag
+func (s *EncryptedStorage[K, V]) seal(key K, value V) ([]byte, error) {
+	plain, err := s.codec.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	id, secret, err := s.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := s.aead(id, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 4+aead.NonceSize(), 4+aead.NonceSize()+len(plain)+aead.Overhead())
+	binary.BigEndian.PutUint32(out, id)
+	if _, err := rand.Read(out[4:]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out[4:], plain, additionalData(out, key)), nil
+}
+
+func (s *EncryptedStorage[K, V]) open(key K, data []byte) (V, error) {
+	var zero V
+	if len(data) < 4 {
+		return zero, errCiphertextTooShort
+	}
+
+	id := binary.BigEndian.Uint32(data)
+	secret, err := s.keys.Key(id)
+	if err != nil {
+		return zero, err
+	}
+	aead, err := s.aead(id, secret)
+	if err != nil {
+		return zero, err
+	}
+
+	body := data[4:]
+	if len(body) < aead.NonceSize() {
+		return zero, errCiphertextTooShort
+	}
+	nonce, sealed := body[:aead.NonceSize()], body[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, additionalData(data, key))
+	if err != nil {
+		return zero, err
+	}
+	return s.codec.Decode(plain)
+}
+
+// decrypted copy of an inner item, false if it can't be opened
+func (s *EncryptedStorage[K, V]) unwrap(key K, inner *Item[[]byte]) (*Item[V], bool) {
+	value, err := s.open(key, inner.Value)
+	if err != nil {
+		return nil, false
+	}
+	return &Item[V]{
+		Value:     value,
+		ExpiresAt: inner.ExpiresAt,
+		HasTTL:    inner.HasTTL,
+		Version:   inner.Version,
+	}, true
+}
+
+func (s *EncryptedStorage[K, V]) Get(key K) (*Item[V], bool) {
+	inner, exists := s.inner.Get(key)
+	if !exists {
+		return nil, false
+	}
+	return s.unwrap(key, inner)
+}
+
+func (s *EncryptedStorage[K, V]) Peek(key K) (*Item[V], bool) {
+	inner, exists := s.inner.Peek(key)
+	if !exists {
+		return nil, false
+	}
+	return s.unwrap(key, inner)
+}
+
+// a value that fails to encode or seal drops the key, like a failed write
+// in the inner storage
+func (s *EncryptedStorage[K, V]) Set(key K, item *Item[V]) {
+	data, err := s.seal(key, item.Value)
+	if err != nil {
+		s.inner.Delete(key)
+		return
+	}
+
+	s.inner.Set(key, &Item[[]byte]{
+		Value:     data,
+		ExpiresAt: item.ExpiresAt,
+		HasTTL:    item.HasTTL,
+		Version:   item.Version,
+	})
+}
+
+func (s *EncryptedStorage[K, V]) Delete(key K) bool {
+	return s.inner.Delete(key)
+}
+
+func (s *EncryptedStorage[K, V]) Clear() {
+	s.inner.Clear()
+}
+
+func (s *EncryptedStorage[K, V]) Size() int {
+	return s.inner.Size()
+}
+
+func (s *EncryptedStorage[K, V]) Keys() []K {
+	return s.inner.Keys()
+}
+
+// decrypts while the inner storage iterates
+func (s *EncryptedStorage[K, V]) All() iter.Seq2[K, *Item[V]] {
+	return func(yield func(K, *Item[V]) bool) {
+		for key, inner := range s.inner.All() {
+			item, ok := s.unwrap(key, inner)
+			if !ok {
+				continue
+			}
+			if !yield(key, item) {
+				return
+			}
+		}
+	}
+}
+
+func (s *EncryptedStorage[K, V]) CleanupExpired() int {
+	return s.inner.CleanupExpired()
+}
+
+func (s *EncryptedStorage[K, V]) Reserve(capacity int) {
+	s.inner.Reserve(capacity)
+}
@@ -2,6 +2,7 @@
 package storage
 
 import (
+	"iter"
 	"sync"
 	"time"
 )
@@ -11,6 +12,8 @@ type Item[V any] struct {
 	Value     V
 	ExpiresAt time.Time
 	HasTTL    bool
+	// Version - bumped by the cache on every write, 0 for fresh items
+	Version uint64
 }
 
 // checks if the item has expired
@@ -53,19 +56,35 @@ func (p *ItemPool[V]) Put(item *Item[V]) {
 	item.Value = zero
 	item.HasTTL = false
 	item.ExpiresAt = time.Time{}
+	item.Version = 0
 	p.pool.Put(item)
 }
 
 // defines the interface for cache storage backends
 type Storage[K comparable, V any] interface {
 	Get(key K) (*Item[V], bool)
+	// Peek - returns item even if expired, without side effects
+	Peek(key K) (*Item[V], bool)
+	// Set - stores item under key. A backend that fails to store it drops
+	// key instead, so reads miss rather than return a stale value
 	Set(key K, item *Item[V])
 	Delete(key K) bool
 	Clear()
 	Size() int
 	Keys() []K
+	// All - iterates items including expired ones, without copying keys
+	All() iter.Seq2[K, *Item[V]]
 	// Cleanup expired items
 	CleanupExpired() int
 	// Reserve space for better memory efficiency
 	Reserve(capacity int)
 }
+
+// OrderedStorage - Storage that can walk keys in ascending order
+type OrderedStorage[K comparable, V any] interface {
+	Storage[K, V]
+	// Ascend - iterates items with key >= from in key order, including expired ones
+	Ascend(from K) iter.Seq2[K, *Item[V]]
+	// Range - iterates items with from <= key < to in key order, including expired ones
+	Range(from, to K) iter.Seq2[K, *Item[V]]
+}
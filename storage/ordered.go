@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"cmp"
+	"iter"
+	"sync"
+)
+
+// in-memory storage kept sorted in a B-tree
+type orderedStorage[K cmp.Ordered, V any] struct {
+	data       btree[K, V]
+	mu         sync.RWMutex
+	itemPool   *ItemPool[V]
+	threadSafe bool
+}
+
+// sorted in-memory storage, needed for range and prefix queries
+func NewOrderedStorage[K cmp.Ordered, V any]() OrderedStorage[K, V] {
+	return NewOrderedStorageWithConfig[K, V](true)
+}
+
+// sorted storage with custom config
+func NewOrderedStorageWithConfig[K cmp.Ordered, V any](threadSafe bool) OrderedStorage[K, V] {
+	return &orderedStorage[K, V]{
+		itemPool:   NewItemPool[V](),
+		threadSafe: threadSafe,
+	}
+}
+
+func (s *orderedStorage[K, V]) Get(key K) (*Item[V], bool) {
+	if s.threadSafe {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	item, exists := s.data.get(key)
+	if !exists {
+		return nil, false
+	}
+
+	if item.IsExpired() {
+		// need write lock for delete
+		if s.threadSafe {
+			s.mu.RUnlock()
+			s.mu.Lock()
+			if item, exists := s.data.get(key); exists && item.IsExpired() {
+				s.data.delete(key)
+				s.itemPool.Put(item)
+			}
+			s.mu.Unlock()
+			s.mu.RLock()
+		} else {
+			s.data.delete(key)
+			s.itemPool.Put(item)
+		}
+		return nil, false
+	}
+
+	return item, true
+}
+
+// no lazy expiry, caller checks IsExpired
+func (s *orderedStorage[K, V]) Peek(key K) (*Item[V], bool) {
+	if s.threadSafe {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	return s.data.get(key)
+}
+
+func (s *orderedStorage[K, V]) Set(key K, item *Item[V]) {
+	if s.threadSafe {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	// recycle old item
+	if old, replaced := s.data.set(key, item); replaced && old != item {
+		s.itemPool.Put(old)
+	}
+}
+
+func (s *orderedStorage[K, V]) Delete(key K) bool {
+	if s.threadSafe {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	item, exists := s.data.delete(key)
+	if exists {
+		s.itemPool.Put(item)
+	}
+	return exists
+}
+
+func (s *orderedStorage[K, V]) Clear() {
+	if s.threadSafe {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	// recycle everything
+	s.data.ascend(*new(K), false, func(_ K, item *Item[V]) bool {
+		s.itemPool.Put(item)
+		return true
+	})
+	s.data = btree[K, V]{}
+}
+
+func (s *orderedStorage[K, V]) Size() int {
+	if s.threadSafe {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	return s.data.size
+}
+
+// keys in ascending order
+func (s *orderedStorage[K, V]) Keys() []K {
+	if s.threadSafe {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	keys := make([]K, 0, s.data.size)
+	s.data.ascend(*new(K), false, func(k K, _ *Item[V]) bool {
+		keys = append(keys, k)
+		return true
+	})
+	return keys
+}
+
+// read lock is held while iterating
+func (s *orderedStorage[K, V]) All() iter.Seq2[K, *Item[V]] {
+	return func(yield func(K, *Item[V]) bool) {
+		if s.threadSafe {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+		}
+
+		s.data.ascend(*new(K), false, yield)
+	}
+}
+
+// read lock is held while iterating
+func (s *orderedStorage[K, V]) Ascend(from K) iter.Seq2[K, *Item[V]] {
+	return func(yield func(K, *Item[V]) bool) {
+		if s.threadSafe {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+		}
+
+		s.data.ascend(from, true, yield)
+	}
+}
+
+// read lock is held while iterating
+func (s *orderedStorage[K, V]) Range(from, to K) iter.Seq2[K, *Item[V]] {
+	return func(yield func(K, *Item[V]) bool) {
+		if s.threadSafe {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+		}
+
+		s.data.ascend(from, true, func(k K, item *Item[V]) bool {
+			return k < to && yield(k, item)
+		})
+	}
+}
+
+// cleanup expired stuff
+func (s *orderedStorage[K, V]) CleanupExpired() int {
+	if s.threadSafe {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	// can't delete while walking the tree
+	var expired []K
+	s.data.ascend(*new(K), false, func(k K, item *Item[V]) bool {
+		if item.IsExpired() {
+			expired = append(expired, k)
+		}
+		return true
+	})
+
+	for _, k := range expired {
+		if item, exists := s.data.delete(k); exists {
+			s.itemPool.Put(item)
+		}
+	}
+	return len(expired)
+}
+
+// nothing to pre-alloc, nodes grow on demand
+func (s *orderedStorage[K, V]) Reserve(capacity int) {}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"iter"
 	"sync"
 )
 
@@ -63,6 +64,17 @@ func (s *memoryStorage[K, V]) Get(key K) (*Item[V], bool) {
 	return item, true
 }
 
+// no lazy expiry, caller checks IsExpired
+func (s *memoryStorage[K, V]) Peek(key K) (*Item[V], bool) {
+	if s.threadSafe {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	item, exists := s.data[key]
+	return item, exists
+}
+
 func (s *memoryStorage[K, V]) Set(key K, item *Item[V]) {
 	if s.threadSafe {
 		s.mu.Lock()
@@ -130,6 +142,22 @@ func (s *memoryStorage[K, V]) Keys() []K {
 	return keys
 }
 
+// read lock is held while iterating
+func (s *memoryStorage[K, V]) All() iter.Seq2[K, *Item[V]] {
+	return func(yield func(K, *Item[V]) bool) {
+		if s.threadSafe {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+		}
+
+		for k, item := range s.data {
+			if !yield(k, item) {
+				return
+			}
+		}
+	}
+}
+
 // cleanup expired stuff
 func (s *memoryStorage[K, V]) CleanupExpired() int {
 	if s.threadSafe {
@@ -1,6 +1,18 @@
 package storage
 
 import (
+	"bytes"
+	"caching-lib/codec"
+	"caching-lib/hasher"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -213,3 +225,769 @@ func TestItemSetTTL(t *testing.T) {
 		t.Error("Expected item to be expired")
 	}
 }
+
+func TestMemoryStoragePeek(t *testing.T) {
+	storage := NewMemoryStorage[string, string]()
+
+	item := &Item[string]{Value: "value1"}
+	item.SetTTL(-time.Hour)
+	storage.Set("key1", item)
+
+	// Peek returns expired items and leaves them in place
+	if peeked, ok := storage.Peek("key1"); !ok || peeked.Value != "value1" {
+		t.Errorf("Expected expired item from Peek, got %v", peeked)
+	}
+	if storage.Size() != 1 {
+		t.Errorf("Expected size 1 after Peek, got %d", storage.Size())
+	}
+
+	if _, ok := storage.Peek("nonexistent"); ok {
+		t.Error("Expected false for non-existent key")
+	}
+}
+
+func TestMemoryStorageAll(t *testing.T) {
+	storage := NewMemoryStorage[string, string]()
+	storage.Set("key1", &Item[string]{Value: "value1"})
+	storage.Set("key2", &Item[string]{Value: "value2"})
+
+	seen := make(map[string]string)
+	for key, item := range storage.All() {
+		seen[key] = item.Value
+	}
+	if len(seen) != 2 || seen["key1"] != "value1" || seen["key2"] != "value2" {
+		t.Errorf("Expected both items, got %v", seen)
+	}
+
+	count := 0
+	for range storage.All() {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("Expected early break to stop iteration, got %d", count)
+	}
+}
+
+func TestOrderedStorage(t *testing.T) {
+	storage := NewOrderedStorage[int, int]()
+	expected := make(map[int]int)
+	rng := rand.New(rand.NewSource(1))
+
+	// enough keys to split and merge nodes a few levels deep
+	for i := 0; i < 20000; i++ {
+		k := rng.Intn(5000)
+		if rng.Intn(3) == 0 {
+			_, exists := expected[k]
+			if storage.Delete(k) != exists {
+				t.Fatalf("Delete(%d) disagreed with map", k)
+			}
+			delete(expected, k)
+			continue
+		}
+		storage.Set(k, &Item[int]{Value: i})
+		expected[k] = i
+	}
+
+	if storage.Size() != len(expected) {
+		t.Fatalf("Expected size %d, got %d", len(expected), storage.Size())
+	}
+
+	keys := storage.Keys()
+	if !sort.IntsAreSorted(keys) || len(keys) != len(expected) {
+		t.Fatal("Expected all keys in ascending order")
+	}
+	for k, v := range expected {
+		if item, ok := storage.Get(k); !ok || item.Value != v {
+			t.Fatalf("Expected %d for key %d, got %v", v, k, item)
+		}
+	}
+
+	var inRange []int
+	for k := range storage.Range(1000, 1100) {
+		inRange = append(inRange, k)
+	}
+	var want []int
+	for _, k := range keys {
+		if k >= 1000 && k < 1100 {
+			want = append(want, k)
+		}
+	}
+	if !slices.Equal(inRange, want) {
+		t.Errorf("Expected range %v, got %v", want, inRange)
+	}
+
+	var first []int
+	for k := range storage.Ascend(4990) {
+		first = append(first, k)
+		if len(first) == 3 {
+			break
+		}
+	}
+	if len(first) == 0 || first[0] < 4990 {
+		t.Errorf("Expected keys from 4990, got %v", first)
+	}
+
+	storage.Clear()
+	if storage.Size() != 0 || len(storage.Keys()) != 0 {
+		t.Error("Expected empty storage after clear")
+	}
+}
+
+func TestOrderedStorageExpiry(t *testing.T) {
+	storage := NewOrderedStorage[string, string]()
+
+	expired := &Item[string]{Value: "old"}
+	expired.SetTTL(time.Millisecond)
+	storage.Set("a", expired)
+	storage.Set("b", &Item[string]{Value: "live"})
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := storage.Peek("a"); !ok {
+		t.Error("Expected Peek to return the expired item")
+	}
+	if removed := storage.CleanupExpired(); removed != 1 {
+		t.Errorf("Expected 1 expired item removed, got %d", removed)
+	}
+	if !slices.Equal(storage.Keys(), []string{"b"}) {
+		t.Errorf("Expected only b left, got %v", storage.Keys())
+	}
+}
+
+func TestArenaStorage(t *testing.T) {
+	storage := NewArenaStorage[string, []byte](256, BytesCodec{})
+
+	item := &Item[[]byte]{Value: []byte("value1"), Version: 7}
+	item.SetTTL(time.Hour)
+	storage.Set("key1", item)
+
+	got, ok := storage.Get("key1")
+	if !ok || string(got.Value) != "value1" || got.Version != 7 || !got.HasTTL {
+		t.Fatalf("Expected value1 with TTL and version, got %+v", got)
+	}
+	if !got.ExpiresAt.Equal(item.ExpiresAt.Truncate(0)) {
+		t.Errorf("Expected expiry %v, got %v", item.ExpiresAt, got.ExpiresAt)
+	}
+
+	// overwrites leave dead bytes until the arena fills up and compacts
+	for i := 0; i < 100; i++ {
+		storage.Set("key1", &Item[[]byte]{Value: []byte(fmt.Sprintf("value-%d", i))})
+		storage.Set(fmt.Sprintf("k%d", i%10), &Item[[]byte]{Value: make([]byte, 16)})
+	}
+	stats := storage.Stats()
+	if stats.Compactions == 0 || stats.Entries != 11 || storage.Size() != 11 {
+		t.Errorf("Expected compactions and 11 entries, got %+v", stats)
+	}
+	if got, _ := storage.Get("key1"); string(got.Value) != "value-99" {
+		t.Errorf("Expected latest value after compaction, got %s", got.Value)
+	}
+
+	if !storage.Delete("k0") || storage.Delete("k0") {
+		t.Error("Expected delete to succeed once")
+	}
+	if len(storage.Keys()) != 10 {
+		t.Errorf("Expected 10 keys, got %d", len(storage.Keys()))
+	}
+
+	expired := &Item[[]byte]{Value: []byte("old")}
+	expired.SetTTL(time.Millisecond)
+	storage.Set("old", expired)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := storage.Peek("old"); !ok {
+		t.Error("Expected Peek to return the expired item")
+	}
+	if removed := storage.CleanupExpired(); removed != 1 {
+		t.Errorf("Expected 1 expired item removed, got %d", removed)
+	}
+
+	storage.Clear()
+	if storage.Size() != 0 || storage.Stats().LiveBytes != 0 {
+		t.Error("Expected empty arena after clear")
+	}
+}
+
+func TestArenaStorageCollisions(t *testing.T) {
+	storage := NewArenaStorage[string, []byte](64, BytesCodec{})
+	// every key lands in the same slot
+	storage.hasher = hasher.Func[string](func(string) uint64 { return 42 })
+
+	for i := 0; i < 20; i++ {
+		storage.Set(fmt.Sprintf("key%d", i), &Item[[]byte]{Value: []byte(fmt.Sprint(i))})
+	}
+	if storage.Stats().Collisions != 19 {
+		t.Errorf("Expected 19 colliding keys, got %d", storage.Stats().Collisions)
+	}
+
+	for i := 0; i < 20; i++ {
+		got, ok := storage.Get(fmt.Sprintf("key%d", i))
+		if !ok || string(got.Value) != fmt.Sprint(i) {
+			t.Errorf("Expected %d for key%d, got %v", i, i, got)
+		}
+	}
+	if _, ok := storage.Get("key20"); ok {
+		t.Error("Expected miss for a colliding absent key")
+	}
+
+	storage.Delete("key0")
+	if got, ok := storage.Get("key5"); !ok || string(got.Value) != "5" {
+		t.Error("Expected overflow keys to survive deleting the slot holder")
+	}
+}
+
+func TestArenaStorageSegments(t *testing.T) {
+	// 16 segments of 4KB
+	storage := NewArenaStorage[string, []byte](64<<10, BytesCodec{})
+	value := make([]byte, 100)
+
+	// churn over a small live set reuses segments instead of growing
+	for i := 0; i < 5000; i++ {
+		storage.Set(fmt.Sprintf("hot%d", i%20), &Item[[]byte]{Value: value, Version: uint64(i)})
+	}
+	stats := storage.Stats()
+	if stats.Segments != 16 || stats.Entries != 20 {
+		t.Errorf("Expected 16 segments holding 20 entries, got %+v", stats)
+	}
+	if got, ok := storage.Get("hot19"); !ok || got.Version != 4999 {
+		t.Errorf("Expected the latest hot19, got %+v", got)
+	}
+
+	// live data past the arena adds segments one at a time
+	for i := 0; i < 1000; i++ {
+		storage.Set(fmt.Sprintf("cold%d", i), &Item[[]byte]{Value: value})
+	}
+	stats = storage.Stats()
+	if stats.Segments <= 16 || stats.Entries != 1020 || stats.LiveBytes > stats.Capacity {
+		t.Errorf("Expected the arena to grow by segments, got %+v", stats)
+	}
+	for i := 0; i < 1000; i += 97 {
+		if _, ok := storage.Get(fmt.Sprintf("cold%d", i)); !ok {
+			t.Errorf("Expected cold%d to survive growth", i)
+		}
+	}
+
+	// deleting a segment's worth of entries frees it for reuse
+	for i := 0; i < 1000; i++ {
+		storage.Delete(fmt.Sprintf("cold%d", i))
+	}
+	grown := storage.Stats().Segments
+	for i := 0; i < 1000; i++ {
+		storage.Set(fmt.Sprintf("warm%d", i), &Item[[]byte]{Value: value})
+	}
+	if stats := storage.Stats(); stats.Segments != grown {
+		t.Errorf("Expected freed segments to be reused, got %d segments after %d", stats.Segments, grown)
+	}
+}
+
+func TestArenaStorageWriteFailure(t *testing.T) {
+	storage := NewArenaStorage[string, []byte](0, BytesCodec{})
+	storage.Set("key", &Item[[]byte]{Value: []byte("old")})
+
+	// larger than a segment
+	storage.Set("key", &Item[[]byte]{Value: make([]byte, 1<<20)})
+	if got, ok := storage.Get("key"); ok {
+		t.Errorf("Expected an oversized write to drop the old value, got %v", got)
+	}
+	if stats := storage.Stats(); stats.WriteErrors != 1 {
+		t.Errorf("Expected 1 write error, got %+v", stats)
+	}
+
+	anys := NewArenaStorage[string, any](0, codec.JSON[any]{})
+	anys.Set("key", &Item[any]{Value: "old"})
+	anys.Set("key", &Item[any]{Value: func() {}})
+	if got, ok := anys.Get("key"); ok {
+		t.Errorf("Expected a failed encode to drop the old value, got %v", got)
+	}
+	if stats := anys.Stats(); stats.EncodeErrors != 1 {
+		t.Errorf("Expected 1 encode error, got %+v", stats)
+	}
+}
+
+func TestArenaStorageCodecs(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	users := NewArenaStorage[string, User](0, codec.Gob[User]{})
+	users.Set("user1", &Item[User]{Value: User{ID: 1, Name: "John"}})
+	if got, ok := users.Get("user1"); !ok || got.Value.Name != "John" {
+		t.Errorf("Expected user John, got %v", got)
+	}
+
+	ints := NewArenaStorage[string, int](0, codec.JSON[int]{})
+	ints.Set("one", &Item[int]{Value: 100})
+	if got, ok := ints.Get("one"); !ok || got.Value != 100 {
+		t.Errorf("Expected 100, got %v", got)
+	}
+}
+
+func TestCompressedStorage(t *testing.T) {
+	for _, compression := range []Compression{CompressionFlate, CompressionGzip} {
+		inner := NewMemoryStorage[string, []byte]()
+		storage := NewCompressedStorageWithConfig[string, []byte](inner, codec.Bytes{}, compression, 6, 64)
+
+		blob := []byte(strings.Repeat(`{"name":"john","role":"admin"},`, 100))
+		item := &Item[[]byte]{Value: blob, Version: 3}
+		item.SetTTL(time.Hour)
+		storage.Set("blob", item)
+		storage.Set("small", &Item[[]byte]{Value: []byte("tiny")})
+
+		got, ok := storage.Get("blob")
+		if !ok || !bytes.Equal(got.Value, blob) || !got.HasTTL || got.Version != 3 {
+			t.Fatalf("Expected blob back with TTL and version, got %v", ok)
+		}
+		if raw, _ := inner.Get("blob"); Compression(raw.Value[0]) != compression || len(raw.Value) >= len(blob) {
+			t.Errorf("Expected blob stored compressed with header %d", compression)
+		}
+		if raw, _ := inner.Get("small"); Compression(raw.Value[0]) != CompressionNone {
+			t.Error("Expected small value stored raw")
+		}
+		if got, _ := storage.Peek("small"); string(got.Value) != "tiny" {
+			t.Errorf("Expected tiny, got %s", got.Value)
+		}
+
+		stats := storage.Stats()
+		if stats.CompressedValues != 1 || stats.UncompressedValues != 1 {
+			t.Errorf("Expected 1 compressed and 1 raw value, got %+v", stats)
+		}
+		if stats.RawBytes != int64(len(blob)+4) || stats.StoredBytes >= stats.RawBytes {
+			t.Errorf("Expected stored bytes below raw bytes, got %+v", stats)
+		}
+	}
+}
+
+func TestCompressedStorageStats(t *testing.T) {
+	storage := NewCompressedStorageWithConfig[string, []byte](NewMemoryStorage[string, []byte](), codec.Bytes{}, CompressionFlate, 6, 64)
+	blob := []byte(strings.Repeat("abcdefgh", 100))
+
+	storage.Set("a", &Item[[]byte]{Value: blob})
+	storage.Set("b", &Item[[]byte]{Value: []byte("tiny")})
+	first := storage.Stats()
+	if first.RawBytes != int64(len(blob)+4) || first.CompressedValues != 1 || first.UncompressedValues != 1 {
+		t.Fatalf("Expected both values counted, got %+v", first)
+	}
+
+	// replacing swaps the old sizes for the new ones
+	storage.Set("a", &Item[[]byte]{Value: blob})
+	storage.Set("b", &Item[[]byte]{Value: []byte("tiny")})
+	if stats := storage.Stats(); stats != first {
+		t.Errorf("Expected rewrites to leave the footprint alone, got %+v", stats)
+	}
+
+	storage.Delete("b")
+	if stats := storage.Stats(); stats.RawBytes != int64(len(blob)) || stats.UncompressedValues != 0 {
+		t.Errorf("Expected b taken out, got %+v", stats)
+	}
+
+	expiring := &Item[[]byte]{Value: blob}
+	expiring.SetTTL(time.Millisecond)
+	storage.Set("c", expiring)
+	storage.Set("d", expiring)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := storage.Get("c"); ok {
+		t.Error("Expected c expired")
+	}
+	if removed := storage.CleanupExpired(); removed != 1 {
+		t.Errorf("Expected d cleaned up, got %d", removed)
+	}
+	if stats := storage.Stats(); stats.CompressedValues != 1 || stats.RawBytes != int64(len(blob)) {
+		t.Errorf("Expected only a left after expiry, got %+v", stats)
+	}
+
+	storage.Clear()
+	if stats := storage.Stats(); stats != (CompressionStats{}) {
+		t.Errorf("Expected empty stats after Clear, got %+v", stats)
+	}
+}
+
+func TestCompressedStorageIncompressible(t *testing.T) {
+	storage := NewCompressedStorageWithConfig[string, []byte](NewMemoryStorage[string, []byte](), codec.Bytes{}, CompressionFlate, 9, 16)
+
+	noise := make([]byte, 1024)
+	rand.New(rand.NewSource(1)).Read(noise)
+	storage.Set("noise", &Item[[]byte]{Value: noise})
+
+	if got, ok := storage.Get("noise"); !ok || !bytes.Equal(got.Value, noise) {
+		t.Error("Expected noise back")
+	}
+	if stats := storage.Stats(); stats.CompressedValues != 0 {
+		t.Errorf("Expected random data stored raw, got %+v", stats)
+	}
+}
+
+func TestCompressedStorageLengthMismatch(t *testing.T) {
+	inner := NewMemoryStorage[string, []byte]()
+	storage := NewCompressedStorageWithConfig[string, []byte](inner, codec.Bytes{}, CompressionFlate, 6, 16)
+	blob := []byte(strings.Repeat("abcdefgh", 100))
+	storage.Set("a", &Item[[]byte]{Value: blob})
+
+	// a stored length shorter than the stream is rejected, not read past
+	raw, _ := inner.Peek("a")
+	_, n := binary.Uvarint(raw.Value[1:])
+	forged := append([]byte{raw.Value[0]}, binary.AppendUvarint(nil, 8)...)
+	forged = append(forged, raw.Value[1+n:]...)
+	inner.Set("a", &Item[[]byte]{Value: forged})
+
+	if got, ok := storage.Get("a"); ok {
+		t.Errorf("Expected a length mismatch to miss, got %d bytes", len(got.Value))
+	}
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	inner := NewMemoryStorage[string, []byte]()
+	keys := NewKeyRing(1, bytes.Repeat([]byte{1}, 32))
+	storage := NewEncryptedStorage[string, string](inner, codec.JSON[string]{}, keys)
+
+	item := &Item[string]{Value: "alice@example.com", Version: 2}
+	item.SetTTL(time.Hour)
+	storage.Set("email", item)
+
+	raw, _ := inner.Get("email")
+	if bytes.Contains(raw.Value, []byte("alice")) {
+		t.Fatal("Expected no plaintext in the inner storage")
+	}
+	if got, ok := storage.Get("email"); !ok || got.Value != "alice@example.com" || !got.HasTTL || got.Version != 2 {
+		t.Fatalf("Expected value back with TTL and version, got %+v", got)
+	}
+
+	// old values keep decrypting after a rotation, new ones use the new key
+	keys.Rotate(2, bytes.Repeat([]byte{2}, 16))
+	storage.Set("phone", &Item[string]{Value: "555-0100"})
+	if got, ok := storage.Get("email"); !ok || got.Value != "alice@example.com" {
+		t.Error("Expected value sealed with key 1 to still open")
+	}
+	if raw, _ := inner.Get("phone"); binary.BigEndian.Uint32(raw.Value) != 2 {
+		t.Error("Expected new value sealed with key 2")
+	}
+
+	keys.Retire(1)
+	if _, ok := storage.Get("email"); ok {
+		t.Error("Expected value sealed with a retired key to be unreadable")
+	}
+	if got, ok := storage.Peek("phone"); !ok || got.Value != "555-0100" {
+		t.Error("Expected value sealed with the current key to open")
+	}
+
+	// tampering fails authentication
+	raw, _ = inner.Get("phone")
+	raw.Value[len(raw.Value)-1] ^= 1
+	if _, ok := storage.Get("phone"); ok {
+		t.Error("Expected tampered value to be rejected")
+	}
+}
+
+func TestEncryptedStorageBinding(t *testing.T) {
+	secret := bytes.Repeat([]byte{7}, 32)
+	keys := NewKeyRing(1, secret)
+	// the ring keeps its own copy
+	clear(secret)
+	keys.Rotate(2, bytes.Repeat([]byte{7}, 32))
+	keys.Rotate(1, bytes.Repeat([]byte{7}, 32))
+
+	inner := NewMemoryStorage[string, []byte]()
+	storage := NewEncryptedStorage[string, string](inner, codec.JSON[string]{}, keys)
+	storage.Set("alice", &Item[string]{Value: "admin"})
+	if got, ok := storage.Get("alice"); !ok || got.Value != "admin" {
+		t.Fatalf("Expected the ring's copy of the key to open values, got %+v", got)
+	}
+
+	// a ciphertext moved to another storage key doesn't open
+	raw, _ := inner.Get("alice")
+	inner.Set("mallory", &Item[[]byte]{Value: bytes.Clone(raw.Value)})
+	if _, ok := storage.Get("mallory"); ok {
+		t.Error("Expected a value copied to another key to be rejected")
+	}
+
+	// nor does one relabelled with another key ID holding the same bytes
+	relabelled := bytes.Clone(raw.Value)
+	binary.BigEndian.PutUint32(relabelled, 2)
+	inner.Set("alice", &Item[[]byte]{Value: relabelled})
+	if _, ok := storage.Get("alice"); ok {
+		t.Error("Expected a relabelled key ID to be rejected")
+	}
+}
+
+func TestEncryptedStorageWriteFailure(t *testing.T) {
+	keys := NewKeyRing(1, bytes.Repeat([]byte{1}, 32))
+	storage := NewEncryptedStorage[string, any](NewMemoryStorage[string, []byte](), codec.JSON[any]{}, keys)
+
+	storage.Set("a", &Item[any]{Value: "old"})
+	storage.Set("a", &Item[any]{Value: func() {}})
+	if got, ok := storage.Get("a"); ok {
+		t.Errorf("Expected a failed encode to drop the old value, got %+v", got)
+	}
+
+	// a key AES can't use fails the seal
+	storage.Set("b", &Item[any]{Value: "old"})
+	keys.Rotate(2, []byte("short"))
+	storage.Set("b", &Item[any]{Value: "new"})
+	if got, ok := storage.Get("b"); ok {
+		t.Errorf("Expected a failed seal to drop the old value, got %+v", got)
+	}
+}
+
+func TestDiskStorage(t *testing.T) {
+	dir := t.TempDir()
+	opts := DiskOptions{CompactInterval: -1}
+
+	storage, err := OpenDiskStorage[string, string](dir, codec.JSON[string]{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Hour).Truncate(0)
+	storage.Set("a", &Item[string]{Value: "1"})
+	storage.Set("b", &Item[string]{Value: "2", ExpiresAt: expires, HasTTL: true, Version: 7})
+	storage.Set("c", &Item[string]{Value: "3"})
+	storage.Set("gone", &Item[string]{Value: "x", ExpiresAt: time.Now().Add(-time.Second), HasTTL: true})
+	storage.Set("a", &Item[string]{Value: "1b"})
+	storage.Delete("c")
+
+	if got, ok := storage.Get("a"); !ok || got.Value != "1b" {
+		t.Fatalf("Expected overwritten value, got %+v", got)
+	}
+	if _, ok := storage.Get("gone"); ok {
+		t.Error("Expected expired item to be missing")
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// everything survives a restart, including expiry and versions
+	storage, err = OpenDiskStorage[string, string](dir, codec.JSON[string]{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	if storage.Size() != 2 {
+		t.Errorf("Expected 2 items after reopening, got %v", storage.Keys())
+	}
+	if got, ok := storage.Get("a"); !ok || got.Value != "1b" || got.HasTTL {
+		t.Errorf("Expected a=1b without TTL, got %+v", got)
+	}
+	got, ok := storage.Get("b")
+	if !ok || got.Value != "2" || !got.HasTTL || !got.ExpiresAt.Equal(expires) || got.Version != 7 {
+		t.Errorf("Expected b with its expiry and version, got %+v", got)
+	}
+	if _, ok := storage.Get("c"); ok {
+		t.Error("Expected tombstone to keep c deleted")
+	}
+
+	storage.Clear()
+	if storage.Size() != 0 {
+		t.Error("Expected empty storage after Clear")
+	}
+	storage.Set("after", &Item[string]{Value: "clear"})
+	if got, ok := storage.Get("after"); !ok || got.Value != "clear" {
+		t.Error("Expected writes to work after Clear")
+	}
+}
+
+func TestDiskStorageTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	opts := DiskOptions{CompactInterval: -1}
+
+	storage, err := OpenDiskStorage[string, []byte](dir, BytesCodec{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.Set("kept", &Item[[]byte]{Value: []byte("safe")})
+	storage.Set("torn", &Item[[]byte]{Value: bytes.Repeat([]byte("x"), 100)})
+	storage.Close()
+
+	// chop the last record in half, as if the process died mid-write
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	sort.Strings(segments)
+	last := segments[len(segments)-1]
+	info, _ := os.Stat(last)
+	if err := os.Truncate(last, info.Size()-50); err != nil {
+		t.Fatal(err)
+	}
+
+	storage, err = OpenDiskStorage[string, []byte](dir, BytesCodec{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := storage.Get("kept"); !ok || string(got.Value) != "safe" {
+		t.Error("Expected records before the torn write to survive")
+	}
+	if _, ok := storage.Get("torn"); ok {
+		t.Error("Expected torn record to be dropped")
+	}
+	if stats := storage.Stats(); stats.TruncatedBytes == 0 {
+		t.Error("Expected torn tail to be truncated")
+	}
+	storage.Set("next", &Item[[]byte]{Value: []byte("ok")})
+	storage.Close()
+
+	// a flipped bit fails the checksum the same way
+	segments, _ = filepath.Glob(filepath.Join(dir, "*.seg"))
+	sort.Strings(segments)
+	last = segments[len(segments)-1]
+	data, _ := os.ReadFile(last)
+	data[len(data)-1] ^= 1
+	os.WriteFile(last, data, 0o644)
+
+	storage, err = OpenDiskStorage[string, []byte](dir, BytesCodec{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	if got, ok := storage.Get("kept"); !ok || string(got.Value) != "safe" {
+		t.Error("Expected record in an earlier segment to survive")
+	}
+	if _, ok := storage.Get("next"); ok {
+		t.Error("Expected corrupted record to be dropped")
+	}
+}
+
+func TestDiskStorageCompaction(t *testing.T) {
+	dir := t.TempDir()
+	opts := DiskOptions{SegmentSize: 1024, CompactInterval: -1}
+
+	storage, err := OpenDiskStorage[string, int](dir, codec.JSON[int]{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for round := range 20 {
+		for i := range 10 {
+			storage.Set(fmt.Sprintf("key%d", i), &Item[int]{Value: round*100 + i})
+		}
+	}
+	for i := 5; i < 10; i++ {
+		storage.Delete(fmt.Sprintf("key%d", i))
+	}
+
+	before := storage.Stats()
+	if before.Segments < 3 {
+		t.Fatalf("Expected writes to span several segments, got %d", before.Segments)
+	}
+	if err := storage.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after := storage.Stats()
+	if after.Segments != 2 || after.Compactions != 1 {
+		t.Errorf("Expected one compacted and one active segment, got %+v", after)
+	}
+	if after.DeadBytes >= before.DeadBytes {
+		t.Errorf("Expected compaction to reclaim dead bytes, before %d after %d", before.DeadBytes, after.DeadBytes)
+	}
+
+	check := func() {
+		t.Helper()
+		if storage.Size() != 5 {
+			t.Errorf("Expected 5 items, got %v", storage.Keys())
+		}
+		for i := range 10 {
+			got, ok := storage.Get(fmt.Sprintf("key%d", i))
+			if i < 5 && (!ok || got.Value != 1900+i) {
+				t.Errorf("Expected key%d=%d, got %+v", i, 1900+i, got)
+			}
+			if i >= 5 && ok {
+				t.Errorf("Expected key%d to stay deleted", i)
+			}
+		}
+	}
+	check()
+
+	// dropped tombstones must not resurrect keys after a restart
+	storage.Close()
+	storage, err = OpenDiskStorage[string, int](dir, codec.JSON[int]{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	check()
+}
+
+func TestDiskStorageConcurrentCompaction(t *testing.T) {
+	storage, err := OpenDiskStorage[string, int](t.TempDir(), codec.JSON[int]{}, DiskOptions{
+		SegmentSize:     512,
+		CompactInterval: time.Millisecond,
+		CompactRatio:    0.1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				key := fmt.Sprintf("w%d-%d", w, i%20)
+				storage.Set(key, &Item[int]{Value: i})
+				if got, ok := storage.Get(key); !ok || got.Value != i {
+					t.Errorf("Expected %s=%d, got %+v", key, i, got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if storage.Size() != 80 {
+		t.Errorf("Expected 80 keys, got %d", storage.Size())
+	}
+	for w := range 4 {
+		for i := 480; i < 500; i++ {
+			key := fmt.Sprintf("w%d-%d", w, i%20)
+			if got, ok := storage.Get(key); !ok || got.Value != i {
+				t.Errorf("Expected %s=%d, got %+v", key, i, got)
+			}
+		}
+	}
+}
+
+func TestDiskStorageWriteFailure(t *testing.T) {
+	dir := t.TempDir()
+	opts := DiskOptions{CompactInterval: -1}
+
+	// a value that can't be encoded drops the key, durably
+	anys, err := OpenDiskStorage[string, any](dir, codec.JSON[any]{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anys.Set("a", &Item[any]{Value: "old"})
+	anys.Set("a", &Item[any]{Value: func() {}})
+	if got, ok := anys.Get("a"); ok {
+		t.Errorf("Expected a failed encode to drop the old value, got %+v", got)
+	}
+	anys.Close()
+
+	anys, err = OpenDiskStorage[string, any](dir, codec.JSON[any]{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := anys.Get("a"); ok {
+		t.Errorf("Expected the tombstone to keep a dropped after reopening, got %+v", got)
+	}
+	anys.Set("b", &Item[any]{Value: "old"})
+	anys.Set("c", &Item[any]{Value: "kept"})
+	anys.Close()
+
+	anys, err = OpenDiskStorage[string, any](dir, codec.JSON[any]{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer anys.Close()
+	// appends to the fresh active segment now fail, b and c live in a sealed one
+	anys.active.file.Close()
+
+	anys.Set("b", &Item[any]{Value: "new"})
+	if got, ok := anys.Get("b"); ok {
+		t.Errorf("Expected a failed append to drop the old value, got %+v", got)
+	}
+	if anys.Delete("c") {
+		t.Error("Expected Delete to fail without a tombstone")
+	}
+	if _, ok := anys.Peek("c"); !ok {
+		t.Error("Expected c to stay after a failed Delete")
+	}
+	// the append for b, its tombstone and the one for c
+	if stats := anys.Stats(); stats.WriteErrors != 3 {
+		t.Errorf("Expected 3 write errors, got %+v", stats)
+	}
+}